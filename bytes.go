@@ -1,6 +1,7 @@
 package no6
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"slices"
@@ -38,6 +39,21 @@ func makeValue(objects []uint64) []byte {
 	return data
 }
 
+// readList returns the UIDs in a posting list.
+func readList(list []byte) []uint64 {
+	if len(list) == 0 {
+		return nil
+	}
+
+	uids := make([]uint64, len(list)/8)
+	for i := range uids {
+		uids[i] = readUID(list[i*8 : i*8+8])
+	}
+	return uids
+}
+
+// appendValue inserts value into the sorted list, unless it is already
+// present in which case list is returned unchanged.
 func appendValue(list []byte, value uint64) []byte {
 	data := writeUID(value)
 
@@ -45,9 +61,29 @@ func appendValue(list []byte, value uint64) []byte {
 		return compareBytes(list[i*8:i*8+8], data) > 0
 	})
 
+	if idx > 0 && bytes.Equal(list[(idx-1)*8:idx*8], data) {
+		return list
+	}
+
 	return slices.Insert(list, idx*8, data...)
 }
 
+// removeValue returns a copy of the sorted list without value. If value is not
+// present list is returned unchanged.
+func removeValue(list []byte, value uint64) []byte {
+	data := writeUID(value)
+
+	idx := sort.Search(len(list)/8, func(i int) bool {
+		return compareBytes(list[i*8:i*8+8], data) >= 0
+	})
+
+	if idx == len(list)/8 || !bytes.Equal(list[idx*8:idx*8+8], data) {
+		return list
+	}
+
+	return slices.Delete(slices.Clone(list), idx*8, idx*8+8)
+}
+
 func compareBytes(a, b []byte) int {
 	for i := 7; i >= 0; i-- {
 		if a[i] < b[i] {
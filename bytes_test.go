@@ -68,3 +68,24 @@ func TestCompareBytes(t *testing.T) {
 	assert.Equal(t, -1, compareBytes(writeUID(4999), writeUID(5001)))
 	assert.Equal(t, 1, compareBytes(writeUID(5001), writeUID(4999)))
 }
+
+func TestAppendValueExisting(t *testing.T) {
+	value := makeValue([]uint64{1, 2, 3})
+
+	assert.Equal(t, makeValue([]uint64{1, 2, 3}), appendValue(value, 1))
+	assert.Equal(t, makeValue([]uint64{1, 2, 3}), appendValue(value, 2))
+	assert.Equal(t, makeValue([]uint64{1, 2, 3}), appendValue(value, 3))
+}
+
+func TestRemoveValue(t *testing.T) {
+	value := makeValue([]uint64{1, 2, 3})
+
+	assert.Equal(t, makeValue([]uint64{2, 3}), removeValue(value, 1))
+	assert.Equal(t, makeValue([]uint64{1, 3}), removeValue(value, 2))
+	assert.Equal(t, makeValue([]uint64{1, 2}), removeValue(value, 3))
+	assert.Equal(t, makeValue([]uint64{1, 2, 3}), removeValue(value, 4))
+	assert.Equal(t, []byte{}, removeValue(makeValue([]uint64{1}), 1))
+
+	// the original list is left untouched
+	assert.Equal(t, makeValue([]uint64{1, 2, 3}), value)
+}
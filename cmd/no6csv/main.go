@@ -42,11 +42,16 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "usage: no6csv in PATH < FILE.csv\n       no6csv out PATH > FILE.csv")
 }
 
-func runIn(path string) error {
+func runIn(path string) (err error) {
 	store, err := no6.Open(path)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if closeErr := store.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 
 	r := csv.NewReader(os.Stdin)
 	line := 0
@@ -74,12 +79,17 @@ func runIn(path string) error {
 }
 
 func runOut(path string) error {
-	store, err := no6.Open(path)
+	store, err := no6.OpenWithOptions(path, no6.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	triples, err := store.Query()
 	if err != nil {
 		return err
 	}
 
-	triples := store.Query()
 	w := csv.NewWriter(os.Stdout)
 
 	for _, triple := range triples {
@@ -0,0 +1,56 @@
+// Command no6vacuum removes unused entries from a no6 database.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"hawx.me/code/no6"
+)
+
+func main() {
+	compact := flag.Bool("compact", false, "rewrite the file afterwards to reclaim disk space")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		printUsage()
+		os.Exit(2)
+		return
+	}
+
+	if err := run(flag.Arg(0), *compact); err != nil {
+		fmt.Fprintln(os.Stderr, "vacuum error: "+err.Error())
+		os.Exit(1)
+		return
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: no6vacuum [-compact] PATH")
+}
+
+func run(path string, compact bool) (err error) {
+	store, err := no6.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := store.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	stats, err := store.Vacuum(no6.VacuumOptions{Compact: compact})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %d values and %d predicates\n", stats.Values, stats.Predicates)
+	if compact {
+		fmt.Printf("compacted from %d bytes to %d bytes\n", stats.SizeBefore, stats.SizeAfter)
+	}
+
+	return nil
+}
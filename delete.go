@@ -3,46 +3,139 @@ package no6
 import "go.etcd.io/bbolt"
 
 func (s *Store) Delete(subject, predicate string) error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		dataBucket := tx.Bucket(bucketData)
-		if dataBucket == nil {
-			return nil
-		}
+	return s.Update(func(tx *Tx) error {
+		return tx.Delete(subject, predicate)
+	})
+}
 
-		subjectUID := dataBucket.Get([]byte(subject))
-		if subjectUID == nil {
-			return nil
-		}
+func (s *Store) delete(tx *bbolt.Tx, subject, predicate string) error {
+	dict := s.readDictionary(tx)
 
-		predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
-		if predicateBucket == nil {
-			return nil
-		}
+	subjectUID := dict.nodeUID(subject)
+	if subjectUID == nil {
+		return nil
+	}
 
-		key := makeKey(readUID(subjectUID), predicate)
-		return predicateBucket.Delete(key)
+	predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+	if predicateBucket == nil {
+		return nil
+	}
+
+	key := makeKey(readUID(subjectUID), predicate)
+
+	if err := removeReverse(tx, predicate, predicateBucket.Get(key), subjectUID); err != nil {
+		return err
+	}
+	if err := removeOrder(tx, dict, predicate, predicateBucket.Get(key), subjectUID); err != nil {
+		return err
+	}
+	if err := removeSearch(tx, dict, predicate, predicateBucket.Get(key), subjectUID); err != nil {
+		return err
+	}
+	if err := removeGeo(tx, dict, predicate, predicateBucket.Get(key), subjectUID); err != nil {
+		return err
+	}
+
+	return predicateBucket.Delete(key)
+}
+
+// DeleteTriple removes object from the values of predicate for subject. Other
+// values for the predicate are kept.
+func (s *Store) DeleteTriple(subject, predicate string, object any) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.DeleteTriple(subject, predicate, object)
 	})
 }
 
+func (s *Store) deleteTriple(tx *bbolt.Tx, subject, predicate string, object any) error {
+	objectData, err := s.typer.Format(object)
+	if err != nil {
+		return err
+	}
+
+	dict := s.readDictionary(tx)
+
+	subjectUID := dict.nodeUID(subject)
+	if subjectUID == nil {
+		return nil
+	}
+
+	predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+	if predicateBucket == nil {
+		return nil
+	}
+
+	key := makeKey(readUID(subjectUID), predicate)
+
+	postingList := predicateBucket.Get(key)
+	if postingList == nil {
+		return nil
+	}
+
+	objectUID := findObject(dict, postingList, objectData)
+	if objectUID == nil {
+		return nil
+	}
+
+	updatedList := removeValue(postingList, readUID(objectUID))
+	if len(updatedList) == len(postingList) {
+		return nil
+	}
+
+	if err := removeReverse(tx, predicate, objectUID, subjectUID); err != nil {
+		return err
+	}
+	if err := removeOrder(tx, dict, predicate, objectUID, subjectUID); err != nil {
+		return err
+	}
+	if err := removeSearch(tx, dict, predicate, objectUID, subjectUID); err != nil {
+		return err
+	}
+	if err := removeGeo(tx, dict, predicate, objectUID, subjectUID); err != nil {
+		return err
+	}
+
+	if len(updatedList) == 0 {
+		return predicateBucket.Delete(key)
+	}
+
+	return predicateBucket.Put(key, updatedList)
+}
+
 func (s *Store) DeleteSubject(subject string) error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		dataBucket := tx.Bucket(bucketData)
-		if dataBucket == nil {
-			return nil
-		}
+	return s.Update(func(tx *Tx) error {
+		return tx.DeleteSubject(subject)
+	})
+}
 
-		subjectUID := dataBucket.Get([]byte(subject))
-		if subjectUID == nil {
-			return nil
-		}
+func (s *Store) deleteSubject(tx *bbolt.Tx, subject string) error {
+	dict := s.readDictionary(tx)
+
+	subjectUID := dict.nodeUID(subject)
+	if subjectUID == nil {
+		return nil
+	}
 
-		return tx.Bucket(bucketPredicates).ForEach(func(p []byte, _ []byte) error {
-			if b := tx.Bucket([]byte("predicate-" + string(p))); b != nil {
-				key := makeKey(readUID(subjectUID), string(p))
-				return b.Delete(key)
+	return tx.Bucket(bucketPredicates).ForEach(func(p []byte, _ []byte) error {
+		if b := tx.Bucket([]byte("predicate-" + string(p))); b != nil {
+			key := makeKey(readUID(subjectUID), string(p))
+
+			if err := removeReverse(tx, string(p), b.Get(key), subjectUID); err != nil {
+				return err
+			}
+			if err := removeOrder(tx, dict, string(p), b.Get(key), subjectUID); err != nil {
+				return err
 			}
+			if err := removeSearch(tx, dict, string(p), b.Get(key), subjectUID); err != nil {
+				return err
+			}
+			if err := removeGeo(tx, dict, string(p), b.Get(key), subjectUID); err != nil {
+				return err
+			}
+
+			return b.Delete(key)
+		}
 
-			return nil
-		})
+		return nil
 	})
 }
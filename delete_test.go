@@ -18,19 +18,28 @@ func TestDelete(t *testing.T) {
 		Triple{"john", "firstName", "John"},
 		Triple{"john", "lastName", "Smith"},
 	)
+
+	triples, err := store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
 	assert.Equal(t,
 		[]Triple{{"john", "firstName", "John"}, {"john", "lastName", "Smith"}},
-		store.Query(Predicates("firstName", "lastName")),
+		triples,
 	)
 
 	store.Delete("john", "firstName")
+
+	triples, err = store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
 	assert.Equal(t,
 		[]Triple{{"john", "lastName", "Smith"}},
-		store.Query(Predicates("firstName", "lastName")),
+		triples,
 	)
 
 	store.Delete("john", "lastName")
-	assert.Equal(t, []Triple(nil), store.Query(Predicates("firstName", "lastName")))
+
+	triples, err = store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple(nil), triples)
 }
 
 func TestDeleteSubject(t *testing.T) {
@@ -46,18 +55,62 @@ func TestDeleteSubject(t *testing.T) {
 		Triple{"dave", "firstName", "Dave"},
 		Triple{"dave", "lastName", "Smith"},
 	)
+
+	triples, err := store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
 	assert.Equal(t,
 		[]Triple{{"john", "firstName", "John"}, {"dave", "firstName", "Dave"},
 			{"john", "lastName", "Smith"}, {"dave", "lastName", "Smith"}},
-		store.Query(Predicates("firstName", "lastName")),
+		triples,
 	)
 
 	store.DeleteSubject("john")
+
+	triples, err = store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
 	assert.Equal(t,
 		[]Triple{{"dave", "firstName", "Dave"}, {"dave", "lastName", "Smith"}},
-		store.Query(Predicates("firstName", "lastName")),
+		triples,
 	)
 
 	store.DeleteSubject("dave")
-	assert.Equal(t, []Triple(nil), store.Query(Predicates("firstName", "lastName")))
+
+	triples, err = store.Query(Predicates("firstName", "lastName"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple(nil), triples)
+}
+
+func TestDeleteTriple(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(
+		Triple{"john", "knows", "dave"},
+		Triple{"john", "knows", "mike"},
+		Triple{"dave", "knows", "mike"},
+	)
+
+	store.DeleteTriple("john", "knows", "dave")
+
+	triples, err := store.Query(Predicates("knows"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"john", "knows", "mike"}, {"dave", "knows", "mike"}},
+		triples,
+	)
+
+	store.DeleteTriple("john", "knows", "mike")
+
+	triples, err = store.Query(Predicates("knows"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"dave", "knows", "mike"}},
+		triples,
+	)
+
+	assert.Nil(t, store.DeleteTriple("john", "knows", "someone"))
+	assert.Nil(t, store.DeleteTriple("nobody", "knows", "mike"))
 }
@@ -0,0 +1,373 @@
+package no6
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"go.etcd.io/bbolt"
+)
+
+type namespace byte
+
+const (
+	namespaceNode    namespace = 'n'
+	namespaceLiteral namespace = 'l'
+	// namespaceUnindexed values are only in the values bucket, so every object
+	// gets its own UID and cannot be found by value.
+	namespaceUnindexed namespace = 'u'
+	// namespaceHash values are found by the hash of their data in the hashes
+	// bucket, rather than the data itself.
+	namespaceHash namespace = 'h'
+)
+
+// namespaceFor returns the namespace objects are stored in for indexer.
+func namespaceFor(indexer Indexer) namespace {
+	switch indexer.(type) {
+	case NilIndexer:
+		return namespaceUnindexed
+	case HashIndexer, SearchIndexer:
+		return namespaceHash
+	}
+
+	return namespaceLiteral
+}
+
+// A dictionary gives access to the buckets mapping values to UIDs within a
+// transaction. When created for reading the buckets may be nil, in which case
+// nothing will be found.
+type dictionary struct {
+	ids      *bbolt.Bucket
+	nodes    *bbolt.Bucket
+	literals *bbolt.Bucket
+	hashes   *bbolt.Bucket
+	values   *bbolt.Bucket
+	logger   *slog.Logger
+}
+
+func (s *Store) readDictionary(tx *bbolt.Tx) *dictionary {
+	return &dictionary{
+		ids:      tx.Bucket(bucketID),
+		nodes:    tx.Bucket(bucketNodes),
+		literals: tx.Bucket(bucketLiterals),
+		hashes:   tx.Bucket(bucketHashes),
+		values:   tx.Bucket(bucketValues),
+		logger:   s.logger,
+	}
+}
+
+func (s *Store) writeDictionary(tx *bbolt.Tx) (*dictionary, error) {
+	ids, err := tx.CreateBucketIfNotExists(bucketID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := tx.CreateBucketIfNotExists(bucketNodes)
+	if err != nil {
+		return nil, err
+	}
+	literals, err := tx.CreateBucketIfNotExists(bucketLiterals)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := tx.CreateBucketIfNotExists(bucketHashes)
+	if err != nil {
+		return nil, err
+	}
+	values, err := tx.CreateBucketIfNotExists(bucketValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dictionary{
+		ids:      ids,
+		nodes:    nodes,
+		literals: literals,
+		hashes:   hashes,
+		values:   values,
+		logger:   s.logger,
+	}, nil
+}
+
+// empty returns true if nothing has been added to the dictionary.
+func (d *dictionary) empty() bool {
+	return d.values == nil
+}
+
+// nodeUID returns the UID for subject, or nil if it has not been added.
+func (d *dictionary) nodeUID(subject string) []byte {
+	if d.nodes == nil {
+		return nil
+	}
+
+	return d.nodes.Get([]byte(subject))
+}
+
+// literalUID returns the UID for the formatted object data, or nil if it has
+// not been added.
+func (d *dictionary) literalUID(data []byte) []byte {
+	if d.literals == nil {
+		return nil
+	}
+
+	return d.literals.Get(data)
+}
+
+// hashUID returns the UID for the formatted object data stored by its hash,
+// or nil if it has not been added.
+func (d *dictionary) hashUID(hash, data []byte) []byte {
+	if d.hashes == nil {
+		return nil
+	}
+
+	uid := d.hashes.Get(hash)
+	if uid == nil {
+		return nil
+	}
+
+	// don't trust the hash alone to say the values are the same
+	if !bytes.Equal(d.literal(uid), data) {
+		return nil
+	}
+
+	return uid
+}
+
+// value returns the namespace and value for uid, or a nil value if uid is
+// not known.
+func (d *dictionary) value(uid []byte) (namespace, []byte) {
+	if d.values == nil {
+		return 0, nil
+	}
+
+	data := d.values.Get(uid)
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	return namespace(data[0]), data[1:]
+}
+
+// node returns the subject for uid, and whether it was found.
+func (d *dictionary) node(uid []byte) (string, bool) {
+	ns, data := d.value(uid)
+	if ns != namespaceNode {
+		return "", false
+	}
+
+	return string(data), true
+}
+
+// literal returns the formatted object data for uid, or nil if it was not
+// found. As objects that are a Ref are stored as the subject they refer to, for
+// a node this is a formatted Ref.
+func (d *dictionary) literal(uid []byte) []byte {
+	ns, data := d.value(uid)
+	switch ns {
+	case namespaceLiteral, namespaceUnindexed, namespaceHash:
+		return data
+	case namespaceNode:
+		return append([]byte{byte(TypeRef)}, data...)
+	}
+
+	return nil
+}
+
+// objectUID returns the UID for the formatted object data stored by indexer,
+// or nil if it has not been added or the indexer does not allow finding it.
+func (d *dictionary) objectUID(indexer Indexer, data []byte) []byte {
+	if subject, ok := refOf(data); ok {
+		return d.nodeUID(subject)
+	}
+
+	switch namespaceFor(indexer) {
+	case namespaceUnindexed:
+		return nil
+	case namespaceHash:
+		return d.hashUID(indexer.Index(data), data)
+	}
+
+	return d.literalUID(data)
+}
+
+// putNode returns the UID for subject, assigning a new one if it has not been
+// seen before.
+func (d *dictionary) putNode(subject string) ([]byte, error) {
+	if uid := d.nodes.Get([]byte(subject)); uid != nil {
+		return uid, nil
+	}
+
+	uid, err := d.add(d.nodes, namespaceNode, []byte(subject))
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.Debug("PUT",
+		slog.String("bucket", string(bucketNodes)),
+		slog.Uint64("uid", readUID(uid)),
+		slog.String("subject", subject))
+
+	return uid, nil
+}
+
+// putObject returns the UID for the formatted object data stored by indexer,
+// assigning a new one if it has not been seen before or cannot be found. A Ref
+// is given the UID of the subject it refers to, whatever the indexer.
+func (d *dictionary) putObject(indexer Indexer, data []byte) ([]byte, error) {
+	if subject, ok := refOf(data); ok {
+		return d.putNode(subject)
+	}
+
+	switch namespaceFor(indexer) {
+	case namespaceUnindexed:
+		uid, err := d.add(nil, namespaceUnindexed, data)
+		if err != nil {
+			return nil, err
+		}
+
+		d.logger.Debug("PUT",
+			slog.String("bucket", string(bucketValues)),
+			slog.Uint64("uid", readUID(uid)),
+			slog.String("literal", string(data)))
+
+		return uid, nil
+
+	case namespaceHash:
+		return d.putHash(indexer.Index(data), data)
+	}
+
+	return d.putLiteral(data)
+}
+
+// putLiteral returns the UID for the formatted object data, assigning a new one
+// if it has not been seen before.
+func (d *dictionary) putLiteral(data []byte) ([]byte, error) {
+	if uid := d.literals.Get(data); uid != nil {
+		return uid, nil
+	}
+
+	uid, err := d.add(d.literals, namespaceLiteral, data)
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.Debug("PUT",
+		slog.String("bucket", string(bucketLiterals)),
+		slog.Uint64("uid", readUID(uid)),
+		slog.String("literal", string(data)))
+
+	return uid, nil
+}
+
+// putHash returns the UID for the formatted object data stored by its hash,
+// assigning a new one if it has not been seen before.
+func (d *dictionary) putHash(hash, data []byte) ([]byte, error) {
+	if uid := d.hashes.Get(hash); uid != nil {
+		if !bytes.Equal(d.literal(uid), data) {
+			return nil, fmt.Errorf("no6: hash of value collides with uid %d", readUID(uid))
+		}
+
+		return uid, nil
+	}
+
+	uid, err := d.add(nil, namespaceHash, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.hashes.Put(hash, uid); err != nil {
+		return nil, err
+	}
+
+	d.logger.Debug("PUT",
+		slog.String("bucket", string(bucketHashes)),
+		slog.Uint64("uid", readUID(uid)),
+		slog.String("hash", fmt.Sprintf("%x", hash)))
+
+	return uid, nil
+}
+
+// reindexObject returns the UID that the object with uid would have if stored
+// by indexer. Unindexed objects, and refs, are left as they are.
+func (d *dictionary) reindexObject(indexer Indexer, uid []byte) ([]byte, error) {
+	if namespaceFor(indexer) == namespaceUnindexed {
+		return uid, nil
+	}
+	if ns, _ := d.value(uid); ns == namespaceNode {
+		return uid, nil
+	}
+
+	data := d.literal(uid)
+	if data == nil {
+		return nil, fmt.Errorf("no6: no value for uid %d", readUID(uid))
+	}
+
+	return d.putObject(indexer, bytes.Clone(data))
+}
+
+// add assigns a new UID to data, recording it in forward (if not nil) and the
+// values bucket.
+func (d *dictionary) add(forward *bbolt.Bucket, ns namespace, data []byte) ([]byte, error) {
+	uid, err := d.nextUID()
+	if err != nil {
+		return nil, err
+	}
+
+	if forward != nil {
+		if err := forward.Put(data, uid); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.values.Put(uid, append([]byte{byte(ns)}, data...)); err != nil {
+		return nil, err
+	}
+
+	return uid, nil
+}
+
+// remove deletes uid, and the value it maps to, from the dictionary.
+func (d *dictionary) remove(uid []byte) error {
+	ns, data := d.value(uid)
+
+	switch ns {
+	case namespaceNode:
+		if err := d.nodes.Delete(data); err != nil {
+			return err
+		}
+	case namespaceLiteral:
+		// an object that was reindexed may no longer be the one found by value
+		if bytes.Equal(d.literals.Get(data), uid) {
+			if err := d.literals.Delete(data); err != nil {
+				return err
+			}
+		}
+	case namespaceHash:
+		hash := HashIndexer{}.Index(data)
+		if bytes.Equal(d.hashes.Get(hash), uid) {
+			if err := d.hashes.Delete(hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.values.Delete(uid)
+}
+
+// nextUID takes the next UID, recording it as the last used straight away so
+// that later calls in the same transaction continue on from it.
+func (d *dictionary) nextUID() ([]byte, error) {
+	lastID := d.ids.Get(keyLast)
+	if lastID == nil {
+		lastID = writeUID(0)
+	}
+
+	uid, _ := incKey(lastID)
+	if err := d.ids.Put(keyLast, uid); err != nil {
+		return nil, err
+	}
+
+	d.logger.Debug("PUT",
+		slog.String("bucket", string(bucketID)),
+		slog.String("key", string(keyLast)),
+		slog.Uint64("lastID", readUID(uid)))
+
+	return uid, nil
+}
@@ -0,0 +1,292 @@
+package no6
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+// The geo-* bucket for a predicate contains (Z(point)+point+ID(subject),
+// Format(point)) pairs for each Point value, where Z(point) is the position of
+// the point along a Z-order curve. Points that are close together are usually
+// close on the curve, and every point within a box is between the positions of
+// its corners, so a box can be found by reading a range of keys and checking
+// each point.
+//
+// It is created when the first Point is added to a predicate, and kept for
+// predicates that are not using the NilIndexer.
+
+func geoBucketName(predicate string) []byte {
+	return []byte("geo-" + predicate)
+}
+
+// A Point is a position on the Earth in degrees.
+type Point struct {
+	Lat, Lng float64
+}
+
+// A Box is the area between the south-west corner Min and the north-east corner
+// Max. If Min.Lng is greater than Max.Lng the box crosses the antimeridian.
+type Box struct {
+	Min, Max Point
+}
+
+// contains returns true if p is within the box, including its edges.
+func (b Box) contains(p Point) bool {
+	if p.Lat < b.Min.Lat || p.Lat > b.Max.Lat {
+		return false
+	}
+
+	if b.Min.Lng > b.Max.Lng {
+		return p.Lng >= b.Min.Lng || p.Lng <= b.Max.Lng
+	}
+
+	return p.Lng >= b.Min.Lng && p.Lng <= b.Max.Lng
+}
+
+// split returns the box as boxes that do not cross the antimeridian.
+func (b Box) split() []Box {
+	if b.Min.Lng <= b.Max.Lng {
+		return []Box{b}
+	}
+
+	return []Box{
+		{Min: b.Min, Max: Point{Lat: b.Max.Lat, Lng: 180}},
+		{Min: Point{Lat: b.Min.Lat, Lng: -180}, Max: b.Max},
+	}
+}
+
+// earthRadius is the mean radius of the Earth in metres.
+const earthRadius = 6371008.8
+
+// Distance returns the distance in metres between p and q, along the surface
+// of the Earth.
+func (p Point) Distance(q Point) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, q.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (q.Lng - p.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// around returns a box containing every point within radius metres of p.
+func (p Point) around(radius float64) Box {
+	dLat := radius / earthRadius * 180 / math.Pi
+
+	box := Box{
+		Min: Point{Lat: math.Max(-90, p.Lat-dLat), Lng: -180},
+		Max: Point{Lat: math.Min(90, p.Lat+dLat), Lng: 180},
+	}
+
+	// near the poles any longitude may be within radius
+	if box.Min.Lat == -90 || box.Max.Lat == 90 {
+		return box
+	}
+
+	dLng := dLat / math.Cos(math.Max(math.Abs(box.Min.Lat), math.Abs(box.Max.Lat))*math.Pi/180)
+	if dLng >= 180 {
+		return box
+	}
+
+	box.Min.Lng = p.Lng - dLng
+	if box.Min.Lng < -180 {
+		box.Min.Lng += 360
+	}
+	box.Max.Lng = p.Lng + dLng
+	if box.Max.Lng > 180 {
+		box.Max.Lng -= 360
+	}
+
+	return box
+}
+
+func (p Point) valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// zorder returns the position of p along a Z-order curve, by interleaving the
+// bits of its scaled longitude and latitude.
+func zorder(p Point) uint64 {
+	lat := uint32((p.Lat + 90) / 180 * math.MaxUint32)
+	lng := uint32((p.Lng + 180) / 360 * math.MaxUint32)
+
+	return spread(lng) | spread(lat)<<1
+}
+
+// spread moves each bit of v so there is a zero bit between each.
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// formatPoint writes p as its position on the curve, so points are ordered by
+// it, followed by the exact coordinates.
+func formatPoint(p Point) ([]byte, error) {
+	if !p.valid() {
+		return nil, fmt.Errorf("no6: point %v is out of range", p)
+	}
+
+	data := make([]byte, 25)
+	data[0] = byte(TypePoint)
+	binary.BigEndian.PutUint64(data[1:], zorder(p))
+	binary.BigEndian.PutUint64(data[9:], math.Float64bits(p.Lat))
+	binary.BigEndian.PutUint64(data[17:], math.Float64bits(p.Lng))
+	return data, nil
+}
+
+func readPoint(data []byte) Point {
+	return Point{
+		Lat: math.Float64frombits(binary.BigEndian.Uint64(data[9:])),
+		Lng: math.Float64frombits(binary.BigEndian.Uint64(data[17:])),
+	}
+}
+
+// pointOf returns the point in the formatted object data, and false if it is
+// not a point.
+func pointOf(data []byte) (Point, bool) {
+	if len(data) != 25 || Type(data[0]) != TypePoint {
+		return Point{}, false
+	}
+
+	return readPoint(data), true
+}
+
+// addGeo records that subjectUID has the formatted object data for predicate,
+// if it is a point.
+func addGeo(tx *bbolt.Tx, predicate string, data, subjectUID []byte) error {
+	if _, ok := pointOf(data); !ok {
+		return nil
+	}
+
+	geoBucket, err := tx.CreateBucketIfNotExists(geoBucketName(predicate))
+	if err != nil {
+		return err
+	}
+
+	return geoBucket.Put(append(bytes.Clone(data[1:]), subjectUID...), data)
+}
+
+// removeGeo records that subjectUID no longer has any of the objects in
+// objectList for predicate.
+func removeGeo(tx *bbolt.Tx, dict *dictionary, predicate string, objectList, subjectUID []byte) error {
+	geoBucket := tx.Bucket(geoBucketName(predicate))
+	if geoBucket == nil {
+		return nil
+	}
+
+	for i := 0; i < len(objectList); i += 8 {
+		data := dict.literal(objectList[i : i+8])
+		if _, ok := pointOf(data); !ok {
+			continue
+		}
+
+		if err := geoBucket.Delete(append(bytes.Clone(data[1:]), subjectUID...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subjectsWithin returns the subjects with a point for predicate in box, and
+// the points that were found for them.
+func subjectsWithin(tx *bbolt.Tx, predicate string, box Box) ([]uint64, map[uint64][]Point) {
+	geoBucket := tx.Bucket(geoBucketName(predicate))
+	if geoBucket == nil {
+		return nil, nil
+	}
+
+	var subjects []uint64
+	points := map[uint64][]Point{}
+
+	for _, part := range box.split() {
+		from := binary.BigEndian.AppendUint64(nil, zorder(part.Min))
+		to := binary.BigEndian.AppendUint64(nil, zorder(part.Max))
+
+		c := geoBucket.Cursor()
+		for k, v := c.Seek(from); k != nil && bytes.Compare(k[:8], to) <= 0; k, v = c.Next() {
+			point := readPoint(v)
+			if !part.contains(point) {
+				continue
+			}
+
+			subject := readUID(k[24:])
+			subjects = append(subjects, subject)
+			points[subject] = append(points[subject], point)
+		}
+	}
+
+	return sortUnique(subjects), points
+}
+
+// subjectsNear returns the subjects with a point for predicate within radius
+// metres of point.
+func subjectsNear(tx *bbolt.Tx, predicate string, point Point, radius float64) []uint64 {
+	candidates, points := subjectsWithin(tx, predicate, point.around(radius))
+
+	return slices.DeleteFunc(candidates, func(subject uint64) bool {
+		for _, p := range points[subject] {
+			if point.Distance(p) <= radius {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+type nearObject struct {
+	point  Point
+	radius float64
+}
+
+// sortByDistance sorts subjects by the distance of their closest point for the
+// predicate in predicateBucket to from. Subjects without a point are placed at
+// the end.
+func sortByDistance(dict *dictionary, predicateBucket *bbolt.Bucket, predicate string, subjects []uint64, from Point, desc bool) {
+	distances := make(map[uint64]float64, len(subjects))
+
+	for _, subject := range subjects {
+		postingList := predicateBucket.Get(makeKey(subject, predicate))
+
+		for i := 0; i < len(postingList); i += 8 {
+			point, ok := pointOf(dict.literal(postingList[i : i+8]))
+			if !ok {
+				continue
+			}
+
+			distance := from.Distance(point)
+			if current, ok := distances[subject]; !ok || distance < current {
+				distances[subject] = distance
+			}
+		}
+	}
+
+	slices.SortStableFunc(subjects, func(a, b uint64) int {
+		da, aok := distances[a]
+		db, bok := distances[b]
+
+		switch {
+		case !aok && !bok:
+			return 0
+		case !aok:
+			return 1
+		case !bok:
+			return -1
+		case desc:
+			return cmp.Compare(db, da)
+		default:
+			return cmp.Compare(da, db)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package no6
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+var (
+	london    = Point{Lat: 51.5074, Lng: -0.1278}
+	brighton  = Point{Lat: 50.8225, Lng: -0.1372}
+	paris     = Point{Lat: 48.8566, Lng: 2.3522}
+	newYork   = Point{Lat: 40.7128, Lng: -74.0060}
+	fiji      = Point{Lat: -17.7134, Lng: 178.0650}
+	tonga     = Point{Lat: -21.1790, Lng: -175.1982}
+	northPole = Point{Lat: 90, Lng: 0}
+)
+
+func TestPointDistance(t *testing.T) {
+	assert.Equal(t, 0.0, london.Distance(london))
+	assert.True(t, math.Abs(london.Distance(paris)-343_500) < 1000)
+	assert.True(t, math.Abs(london.Distance(newYork)-5_570_000) < 10_000)
+	assert.Equal(t, london.Distance(paris), paris.Distance(london))
+}
+
+func TestTyperPoint(t *testing.T) {
+	typer := &Typer{}
+
+	for _, point := range []Point{london, newYork, fiji, tonga, northPole, {Lat: -90, Lng: -180}} {
+		typ, read := mustRead(typer, mustFormat(typer, point))
+		assert.Equal(t, TypePoint, typ)
+		assert.Equal(t, point, read)
+	}
+
+	_, err := typer.Format(Point{Lat: 91, Lng: 0})
+	assert.NotNil(t, err)
+}
+
+func TestGeo(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"london", "location", london},
+		Triple{"brighton", "location", brighton},
+		Triple{"paris", "location", paris},
+		Triple{"new-york", "location", newYork},
+		Triple{"fiji", "location", fiji},
+		Triple{"tonga", "location", tonga},
+		Triple{"pole", "location", northPole},
+		Triple{"nowhere", "location", "unknown"},
+		Triple{"nowhere", "name", "Nowhere"},
+	))
+
+	t.Run("Within", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("location").Within(Box{
+			Min: Point{Lat: 48, Lng: -1},
+			Max: Point{Lat: 52, Lng: 3},
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"london", "brighton", "paris"}, subjects)
+	})
+
+	t.Run("Within across antimeridian", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("location").Within(Box{
+			Min: Point{Lat: -25, Lng: 170},
+			Max: Point{Lat: -10, Lng: -170},
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fiji", "tonga"}, subjects)
+	})
+
+	t.Run("Near", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("location").Near(london, 100_000))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"london", "brighton"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("location").Near(london, 400_000))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"london", "brighton", "paris"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("location").Near(fiji, 1_000_000))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fiji", "tonga"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("location").Near(Point{Lat: 89.9, Lng: 120}, 20_000))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"pole"}, subjects)
+	})
+
+	t.Run("Sort by distance", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(
+			Predicates("location").Near(london, 6_000_000),
+			Sort("location").Distance(london),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"london", "brighton", "paris", "pole", "new-york"}, subjects)
+
+		subjects, err = store.QuerySubjects(
+			Predicates("location"),
+			Sort("location").Distance(paris).Desc(),
+			Limit(2),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"tonga", "fiji"}, subjects)
+
+		subjects, err = store.QuerySubjects(
+			Predicates("location"),
+			Sort("location").Distance(paris),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, "nowhere", subjects[len(subjects)-1])
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		triples, err := store.Query(Predicates("location").Near(paris, 1000))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"paris", "location", paris}}, triples)
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		assert.Nil(t, store.DeleteTriple("brighton", "location", brighton))
+		assert.Nil(t, store.Delete("paris", "location"))
+		assert.Nil(t, store.DeleteSubject("london"))
+
+		subjects, err := store.QuerySubjects(Predicates("location").Near(london, 400_000))
+		assert.Nil(t, err)
+		assert.Len(t, subjects, 0)
+
+		store.db.View(func(tx *bbolt.Tx) error {
+			assert.Equal(t, 4, tx.Bucket(geoBucketName("location")).Stats().KeyN)
+			return nil
+		})
+	})
+
+	t.Run("not indexed", func(t *testing.T) {
+		assert.Nil(t, store.SetIndexer("location", HashIndexer{}))
+
+		_, err := store.QuerySubjects(Predicates("location").Near(fiji, 1000))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+
+		assert.Nil(t, store.SetIndexer("location", nil))
+
+		subjects, err := store.QuerySubjects(Predicates("location").Near(fiji, 1000))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"fiji"}, subjects)
+	})
+}
@@ -2,6 +2,11 @@ package no6
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
 )
 
 // Indexers can be configured for different predicates, but apply across
@@ -22,34 +27,253 @@ import (
 //
 // So perhaps the first is an indexer and the second is a typer?
 
+// ErrNotIndexed is returned when querying by the value of a predicate that uses
+// an indexer that does not allow it.
+var ErrNotIndexed = errors.New("no6: predicate is not indexed for this query")
+
+// The indexers bucket contains (predicate, name) pairs recording which indexer
+// each predicate uses. Predicates without an entry use the default, which is
+// like FullTextIndexer but without an ordered index.
+var bucketIndexers = []byte("indexers")
+
+// An Indexer decides how the values of a predicate are stored, and so which
+// queries can be made on them. The indexers are a closed set, only NilIndexer,
+// FullTextIndexer, HashIndexer and SearchIndexer can be used: each needs its
+// own support when storing and querying values, and is recorded in the database
+// by name so that it can be found again when opened.
 type Indexer interface {
 	// Index returns how the value should be stored. It may return the same input
-	// slice.
+	// slice. If it returns an empty slice the value is not stored in a way that
+	// can be found by equality.
 	Index([]byte) []byte
-	// Less returns true if a < b. We only need this operation as equality can
-	// compare uids.
-	Less(a, b []byte) bool
+
+	// name is recorded in the database for the predicates using the indexer.
+	name() string
 }
 
 // A NilIndexer will not store objects in a way that can be queried or sorted.
+// Refs are still recorded in the reverse index, so can be found by Incoming.
+//
+// Each object still gets its own entry in the dictionary, it is only kept out
+// of the literals bucket and the indexes, so identical values are stored again
+// for each subject. As an object can't be found by value, putting one compares
+// it against every existing value the subject has for the predicate. For
+// predicates where subjects have many values HashIndexer is better.
 type NilIndexer struct{}
 
 func (i NilIndexer) Index(data []byte) []byte {
 	return []byte{}
 }
 
-func (i NilIndexer) Less(a, b []byte) bool {
-	return false
-}
+func (i NilIndexer) name() string { return "nil" }
 
 // A FullTextIndexer will store objects such that they can be queried and
-// sorted.
+// sorted. It keeps an ordered index of the values, so Lt, Gt and Sort do not
+// need to compare every value.
 type FullTextIndexer struct{}
 
 func (i FullTextIndexer) Index(data []byte) []byte {
 	return data
 }
 
-func (i FullTextIndexer) Less(a, b []byte) bool {
-	return bytes.Compare(a, b) == -1
+func (i FullTextIndexer) name() string { return "full-text" }
+
+// A HashIndexer will store objects by a hash of their value, so that large
+// values, like long pieces of text, are not used as keys. Objects can be queried
+// for equality but not compared or sorted. Identical values are stored once.
+type HashIndexer struct{}
+
+func (i HashIndexer) Index(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+func (i HashIndexer) name() string { return "hash" }
+
+var indexersByName = map[string]Indexer{
+	NilIndexer{}.name():      NilIndexer{},
+	FullTextIndexer{}.name(): FullTextIndexer{},
+	HashIndexer{}.name():     HashIndexer{},
+
+	SearchIndexer{}.name():           SearchIndexer{},
+	SearchIndexer{Stem: true}.name(): SearchIndexer{Stem: true},
+}
+
+// SetIndexer sets the indexer used for the values of predicate, a nil indexer
+// returns it to the default. Any existing values are reindexed.
+func (s *Store) SetIndexer(predicate string, indexer Indexer) error {
+	return s.update(func(tx *bbolt.Tx) error {
+		return s.setIndexer(tx, predicate, indexer)
+	})
+}
+
+// Indexer returns the indexer used for predicate, or nil if it uses the
+// default.
+func (s *Store) Indexer(predicate string) (Indexer, error) {
+	var indexer Indexer
+
+	err := s.view(func(tx *bbolt.Tx) (err error) {
+		indexer, err = indexerFor(tx, predicate)
+		return err
+	})
+
+	return indexer, err
+}
+
+func (s *Store) setIndexer(tx *bbolt.Tx, predicate string, indexer Indexer) error {
+	indexersBucket, err := tx.CreateBucketIfNotExists(bucketIndexers)
+	if err != nil {
+		return err
+	}
+
+	if indexer == nil {
+		err = indexersBucket.Delete([]byte(predicate))
+	} else {
+		err = indexersBucket.Put([]byte(predicate), []byte(indexer.name()))
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.reindex(tx, predicate, indexer)
+}
+
+// indexerFor returns the indexer recorded for predicate, or nil for the
+// default.
+func indexerFor(tx *bbolt.Tx, predicate string) (Indexer, error) {
+	indexersBucket := tx.Bucket(bucketIndexers)
+	if indexersBucket == nil {
+		return nil, nil
+	}
+
+	name := indexersBucket.Get([]byte(predicate))
+	if name == nil {
+		return nil, nil
+	}
+
+	indexer, ok := indexersByName[string(name)]
+	if !ok {
+		return nil, fmt.Errorf("no6: unknown indexer %q for predicate %q", name, predicate)
+	}
+
+	return indexer, nil
+}
+
+// searchable returns the indexer for predicate, or an error if its values
+// cannot be queried by equality, or if ordered cannot be compared or sorted.
+func searchable(tx *bbolt.Tx, predicate string, ordered bool) (Indexer, error) {
+	indexer, err := indexerFor(tx, predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	switch indexer.(type) {
+	case NilIndexer:
+		return nil, fmt.Errorf("%w: %q uses %s indexer", ErrNotIndexed, predicate, indexer.name())
+	case HashIndexer:
+		if ordered {
+			return nil, fmt.Errorf("%w: %q uses %s indexer which can't be ordered", ErrNotIndexed, predicate, indexer.name())
+		}
+	}
+
+	return indexer, nil
+}
+
+// reindex moves the values of predicate into the namespace used by indexer,
+// then rebuilds the reverse, ordered, search and geo indexes for it.
+func (s *Store) reindex(tx *bbolt.Tx, predicate string, indexer Indexer) error {
+	for _, name := range [][]byte{
+		reverseBucketName(predicate),
+		orderBucketName(predicate),
+		termsBucketName(predicate),
+		lengthsBucketName(predicate),
+		geoBucketName(predicate),
+	} {
+		if tx.Bucket(name) != nil {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch indexer.(type) {
+	case FullTextIndexer:
+		if _, err := tx.CreateBucket(orderBucketName(predicate)); err != nil {
+			return err
+		}
+	case SearchIndexer:
+		if _, err := tx.CreateBucket(termsBucketName(predicate)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(lengthsBucketName(predicate)); err != nil {
+			return err
+		}
+	}
+
+	predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+	if predicateBucket == nil {
+		return nil
+	}
+
+	dict, err := s.writeDictionary(tx)
+	if err != nil {
+		return err
+	}
+
+	type rewrite struct {
+		key, list []byte
+	}
+
+	var rewrites []rewrite
+	if err := predicateBucket.ForEach(func(k, v []byte) error {
+		var updatedList []byte
+
+		for i := 0; i < len(v); i += 8 {
+			objectUID, err := dict.reindexObject(indexer, v[i:i+8])
+			if err != nil {
+				return err
+			}
+
+			updatedList = appendValue(updatedList, readUID(objectUID))
+		}
+
+		rewrites = append(rewrites, rewrite{key: bytes.Clone(k), list: updatedList})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, r := range rewrites {
+		if err := predicateBucket.Put(r.key, r.list); err != nil {
+			return err
+		}
+
+		_, unindexed := indexer.(NilIndexer)
+
+		for i := 0; i < len(r.list); i += 8 {
+			objectUID := r.list[i : i+8]
+
+			if _, ok := refOf(dict.literal(objectUID)); unindexed && !ok {
+				continue
+			}
+
+			if err := addReverse(tx, predicate, objectUID, r.key[:8]); err != nil {
+				return err
+			}
+			if unindexed {
+				continue
+			}
+			if err := addGeo(tx, predicate, dict.literal(objectUID), r.key[:8]); err != nil {
+				return err
+			}
+			if err := addOrder(tx, predicate, dict.literal(objectUID), r.key[:8]); err != nil {
+				return err
+			}
+			if err := addSearch(tx, predicate, dict.literal(objectUID), r.key[:8]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
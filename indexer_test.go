@@ -0,0 +1,226 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestSetIndexer(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	indexer, err := store.Indexer("notes")
+	assert.Nil(t, err)
+	assert.Nil(t, indexer)
+
+	assert.Nil(t, store.SetIndexer("notes", NilIndexer{}))
+	assert.Nil(t, store.Close())
+
+	store, _ = Open(file.Name())
+	defer store.Close()
+
+	indexer, err = store.Indexer("notes")
+	assert.Nil(t, err)
+	assert.Equal(t, NilIndexer{}, indexer)
+
+	assert.Nil(t, store.SetIndexer("notes", nil))
+
+	indexer, err = store.Indexer("notes")
+	assert.Nil(t, err)
+	assert.Nil(t, indexer)
+}
+
+func TestNilIndexer(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.SetIndexer("notes", NilIndexer{}))
+
+	store.PutTriples(
+		Triple{"a", "notes", "hello"},
+		Triple{"a", "notes", "hello"},
+		Triple{"b", "notes", "hello"},
+		Triple{"b", "name", "bob"},
+	)
+
+	t.Run("values are stored", func(t *testing.T) {
+		triples, err := store.Query(Subjects("a"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"a", "notes", "hello"}}, triples)
+	})
+
+	t.Run("values are not shared", func(t *testing.T) {
+		triples, err := store.Query(Subjects("b"))
+		assert.Nil(t, err)
+		assert.Len(t, triples, 2)
+	})
+
+	t.Run("queries are rejected", func(t *testing.T) {
+		for name, matcher := range map[string]PredicatesMatcher{
+			"Eq": Predicates("notes").Eq("hello"),
+			"Ne": Predicates("notes").Ne("hello"),
+			"Lt": Predicates("notes").Lt("z"),
+			"Gt": Predicates("notes").Gt("a"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := store.QuerySubjects(matcher)
+				assert.True(t, errors.Is(err, ErrNotIndexed))
+
+				_, err = store.Query(matcher)
+				assert.True(t, errors.Is(err, ErrNotIndexed))
+			})
+		}
+	})
+
+	t.Run("sort is rejected", func(t *testing.T) {
+		_, err := store.QuerySubjects(Predicates("notes"), Sort("notes"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+
+	t.Run("Has is allowed", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("notes"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, subjects)
+	})
+
+	t.Run("DeleteTriple", func(t *testing.T) {
+		assert.Nil(t, store.DeleteTriple("a", "notes", "hello"))
+
+		triples, err := store.Query(Subjects("a"))
+		assert.Nil(t, err)
+		assert.Len(t, triples, 0)
+	})
+}
+
+func TestReindex(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.SetIndexer("name", NilIndexer{}))
+
+	store.PutTriples(
+		Triple{"a", "name", "carol"},
+		Triple{"b", "name", "alice"},
+		Triple{"c", "name", "bob"},
+		Triple{"d", "friend", "alice"},
+	)
+
+	assert.Nil(t, store.SetIndexer("name", FullTextIndexer{}))
+
+	subjects, err := store.QuerySubjects(Predicates("name").Eq("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("friend").Eq("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("name"), Sort("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c", "a"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("name").Lt("bob"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b"}, subjects)
+
+	assert.Nil(t, store.SetIndexer("name", nil))
+
+	subjects, err = store.QuerySubjects(Predicates("name").Gt("alice"), Sort("name").Desc())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "c"}, subjects)
+}
+
+func TestHashIndexer(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	// too large to be a key, so must be added after setting the indexer
+	body := strings.Repeat("lorem ipsum ", 5000)
+
+	assert.Nil(t, store.PutTriples(Triple{"b", "body", "short"}))
+	assert.Nil(t, store.SetIndexer("body", HashIndexer{}))
+	assert.Nil(t, store.PutTriples(
+		Triple{"a", "body", body},
+		Triple{"c", "body", body},
+		Triple{"c", "body", body},
+	))
+
+	t.Run("values are shared", func(t *testing.T) {
+		store.db.View(func(tx *bbolt.Tx) error {
+			dict := store.readDictionary(tx)
+			predicateBucket := tx.Bucket([]byte("predicate-body"))
+			a := predicateBucket.Get(makeKey(readUID(dict.nodeUID("a")), "body"))
+			c := predicateBucket.Get(makeKey(readUID(dict.nodeUID("c")), "body"))
+
+			assert.Len(t, a, 8)
+			assert.Equal(t, a, c)
+			return nil
+		})
+	})
+
+	t.Run("values are not keys", func(t *testing.T) {
+		store.db.View(func(tx *bbolt.Tx) error {
+			assert.Nil(t, tx.Bucket(bucketLiterals).Get(mustFormat(store.typer, body)))
+			assert.NotNil(t, tx.Bucket(bucketHashes).Get(HashIndexer{}.Index(mustFormat(store.typer, body))))
+			return nil
+		})
+	})
+
+	t.Run("Eq", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("body").Eq(body))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "c"}, subjects)
+
+		triples, err := store.Query(Predicates("body").Eq("short"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"b", "body", "short"}}, triples)
+	})
+
+	t.Run("Ne", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("body").Ne(body))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b"}, subjects)
+	})
+
+	t.Run("ordering is rejected", func(t *testing.T) {
+		_, err := store.QuerySubjects(Predicates("body").Lt("z"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+
+		_, err = store.QuerySubjects(Predicates("body").Gt("a"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+
+		_, err = store.QuerySubjects(Predicates("body"), Sort("body"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+
+		_, err = store.Query(Predicates("body").Lt("z"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+
+	t.Run("DeleteTriple", func(t *testing.T) {
+		assert.Nil(t, store.DeleteTriple("a", "body", body))
+
+		subjects, err := store.QuerySubjects(Predicates("body").Eq(body))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"c"}, subjects)
+	})
+}
@@ -1,115 +1,132 @@
 package no6
 
 import (
-	"encoding/binary"
+	"bytes"
 	"log/slog"
 
 	"go.etcd.io/bbolt"
 )
 
-func (s *Store) PutTriples(triples ...Triple) {
-	for _, triple := range triples {
-		_ = s.Put(triple.Subject, triple.Predicate, triple.Object)
-	}
+// PutTriples writes all of the triples in a single transaction. If any of them
+// fail to be written the first error is returned and none of the triples will
+// have been stored.
+//
+// Subjects and objects that have not been seen before are given IDs in the
+// order they appear in triples, each taking the value after the one recorded in
+// the id bucket. As the record is only committed with the rest of the batch a
+// failed batch does not use up any IDs.
+func (s *Store) PutTriples(triples ...Triple) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.PutTriples(triples...)
+	})
 }
 
+// Put writes a single triple in its own transaction. As the subject is checked
+// against the shapes for its types once the triple is written, it can't give a
+// subject a type whose shape needs other values; use PutTriples or Update.
 func (s *Store) Put(subject, predicate string, object any) error {
-	// TODO: should probably make sure the ID is updated first, otherwise the next
-	// operation might do something weird.
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		idBucket, err := tx.CreateBucketIfNotExists(bucketID)
-		if err != nil {
-			return err
-		}
+	return s.Update(func(tx *Tx) error {
+		return tx.Put(subject, predicate, object)
+	})
+}
 
-		lastID := idBucket.Get(keyLast)
-		if lastID == nil {
-			lastID = make([]byte, 8)
-			binary.LittleEndian.PutUint64(lastID, 0)
-		}
+func (s *Store) put(tx *bbolt.Tx, subject, predicate string, object any) error {
+	objectData, err := s.typer.Format(object)
+	if err != nil {
+		return err
+	}
 
-		dataBucket, err := tx.CreateBucketIfNotExists(bucketData)
-		if err != nil {
-			return err
-		}
-		predicatesBucket, err := tx.CreateBucketIfNotExists(bucketPredicates)
-		if err != nil {
-			return err
-		}
-		predicateBucket, err := tx.CreateBucketIfNotExists([]byte("predicate-" + predicate))
-		if err != nil {
-			return err
-		}
+	dict, err := s.writeDictionary(tx)
+	if err != nil {
+		return err
+	}
+	predicatesBucket, err := tx.CreateBucketIfNotExists(bucketPredicates)
+	if err != nil {
+		return err
+	}
+	predicateBucket, err := tx.CreateBucketIfNotExists([]byte("predicate-" + predicate))
+	if err != nil {
+		return err
+	}
 
-		if err := predicatesBucket.Put([]byte(predicate), []byte{}); err != nil {
-			return err
-		}
-		s.logger.Debug("PUT",
-			slog.String("bucket", string(bucketPredicates)),
-			slog.String("key", predicate))
-
-		subjectUID := dataBucket.Get([]byte(subject))
-		if subjectUID == nil {
-			subjectUID, lastID = incKey(lastID)
-			if err := dataBucket.Put(subjectUID, []byte(subject)); err != nil {
-				return err
-			}
-			if err := dataBucket.Put([]byte(subject), subjectUID); err != nil {
-				return err
-			}
-
-			s.logger.Debug("PUT",
-				slog.String("bucket", string(bucketData)),
-				slog.Uint64("uid", readUID(subjectUID)),
-				slog.String("subject", subject))
-		}
+	if err := predicatesBucket.Put([]byte(predicate), []byte{}); err != nil {
+		return err
+	}
+	s.logger.Debug("PUT",
+		slog.String("bucket", string(bucketPredicates)),
+		slog.String("key", predicate))
 
-		objectUID := dataBucket.Get(s.typer.Format(object))
-		if objectUID == nil {
-			objectUID, lastID = incKey(lastID)
-			objectData := s.typer.Format(object)
-
-			if err := dataBucket.Put(objectUID, objectData); err != nil {
-				return err
-			}
-			if err := dataBucket.Put(objectData, objectUID); err != nil {
-				return err
-			}
-
-			s.logger.Debug("PUT",
-				slog.String("bucket", string(bucketData)),
-				slog.Uint64("uid", readUID(objectUID)),
-				slog.Any("object", object))
-		}
+	subjectUID, err := dict.putNode(subject)
+	if err != nil {
+		return err
+	}
+
+	indexer, err := indexerFor(tx, predicate)
+	if err != nil {
+		return err
+	}
+
+	key := makeKey(readUID(subjectUID), predicate)
+	postingList := predicateBucket.Get(key)
 
-		key := makeKey(readUID(subjectUID), predicate)
-
-		postingList := predicateBucket.Get(key)
-		if postingList == nil {
-			if err := predicateBucket.Put(key, appendValue([]byte{}, readUID(objectUID))); err != nil {
-				return err
-			}
-
-			s.logger.Debug("PUT",
-				slog.String("bucket", "predicate-"+predicate),
-				slog.String("key", prettyPrintKey(key)),
-				slog.String("value", prettyPrintList(appendValue([]byte{}, readUID(objectUID)))))
-		} else {
-			if err := predicateBucket.Put(key, appendValue(postingList, readUID(objectUID))); err != nil {
-				return err
-			}
-
-			s.logger.Debug("PUT",
-				slog.String("bucket", "predicate-"+predicate),
-				slog.String("key", prettyPrintKey(key)),
-				slog.String("value", prettyPrintList(appendValue(postingList, readUID(objectUID)))))
+	// unindexed objects can't be found by value, so check the subject doesn't
+	// already have it
+	if namespaceFor(indexer) == namespaceUnindexed && findObject(dict, postingList, objectData) != nil {
+		return nil
+	}
+
+	objectUID, err := dict.putObject(indexer, objectData)
+	if err != nil {
+		return err
+	}
+
+	updatedList := appendValue(postingList, readUID(objectUID))
+	if len(updatedList) == len(postingList) {
+		return nil
+	}
+
+	if err := predicateBucket.Put(key, updatedList); err != nil {
+		return err
+	}
+
+	s.logger.Debug("PUT",
+		slog.String("bucket", "predicate-"+predicate),
+		slog.String("key", prettyPrintKey(key)),
+		slog.String("value", prettyPrintList(updatedList)))
+
+	// unindexed objects are kept out of every index, except that refs are
+	// always recorded in the reverse index so they can be followed backwards
+	if namespaceFor(indexer) == namespaceUnindexed {
+		if _, ok := refOf(objectData); !ok {
+			return nil
 		}
 
-		s.logger.Debug("PUT",
-			slog.String("bucket", string(bucketID)),
-			slog.String("key", string(keyLast)),
-			slog.Uint64("lastID", readUID(lastID)))
+		return addReverse(tx, predicate, objectUID, subjectUID)
+	}
 
-		return idBucket.Put(keyLast, lastID)
-	})
+	if err := addReverse(tx, predicate, objectUID, subjectUID); err != nil {
+		return err
+	}
+	if err := addGeo(tx, predicate, objectData, subjectUID); err != nil {
+		return err
+	}
+
+	if err := addOrder(tx, predicate, objectData, subjectUID); err != nil {
+		return err
+	}
+
+	return addSearch(tx, predicate, objectData, subjectUID)
+}
+
+// findObject returns the UID in postingList for the formatted object data, or
+// nil if it is not present. It looks up each value in turn, so is only used
+// when the object can't be found by value.
+func findObject(dict *dictionary, postingList, data []byte) []byte {
+	for i := 0; i < len(postingList); i += 8 {
+		if bytes.Equal(dict.literal(postingList[i:i+8]), data) {
+			return postingList[i : i+8]
+		}
+	}
+
+	return nil
 }
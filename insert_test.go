@@ -0,0 +1,90 @@
+package no6
+
+import (
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestPutTriples(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	err := store.PutTriples(
+		Triple{"john", "firstName", "John"},
+		Triple{"john", "knows", "dave"},
+		Triple{"dave", "firstName", "Dave"},
+	)
+	assert.Nil(t, err)
+
+	triples, err := store.Query(Predicates("firstName"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"john", "firstName", "John"}, {"dave", "firstName", "Dave"}},
+		triples,
+	)
+
+	var lastID uint64
+	store.db.View(func(tx *bbolt.Tx) error {
+		lastID = readUID(tx.Bucket(bucketID).Get(keyLast))
+		return nil
+	})
+	// john, "John", "dave", dave, "Dave"
+	assert.Equal(t, uint64(5), lastID)
+}
+
+func TestPutTriplesRollback(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	err := store.PutTriples(
+		Triple{"john", "firstName", "John"},
+		Triple{"", "firstName", "Nobody"},
+	)
+	assert.Equal(t, bbolt.ErrKeyRequired, err)
+
+	triples, err := store.Query(Predicates("firstName"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple(nil), triples)
+
+	err = store.PutTriples(Triple{"dave", "firstName", "Dave"})
+	assert.Nil(t, err)
+
+	var lastID uint64
+	store.db.View(func(tx *bbolt.Tx) error {
+		lastID = readUID(tx.Bucket(bucketID).Get(keyLast))
+		return nil
+	})
+	// the failed batch must not have used up any IDs
+	assert.Equal(t, uint64(2), lastID)
+}
+
+func TestPutExisting(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.Put("john", "knows", "dave")
+	store.Put("john", "knows", "dave")
+	store.PutTriples(
+		Triple{"john", "knows", "mike"},
+		Triple{"john", "knows", "dave"},
+	)
+
+	triples, err := store.Query(Predicates("knows"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"john", "knows", "dave"}, {"john", "knows", "mike"}},
+		triples,
+	)
+}
@@ -0,0 +1,170 @@
+package no6
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A Literal is a value with a language or datatype, like the literals of RDF.
+// For example "Hello"@en is
+//
+//	Literal{Value: "Hello", Lang: "en"}
+//
+// and "2024-01-01"^^xsd:date is
+//
+//	Literal{Value: "2024-01-01", Datatype: "xsd:date"}
+//
+// A Literal with neither a Lang or Datatype is stored as its Value, so will be
+// read back as the Value alone.
+type Literal struct {
+	Value    any
+	Lang     string
+	Datatype string
+}
+
+func (l Literal) String() string {
+	switch {
+	case l.Lang != "":
+		return fmt.Sprintf("%q@%s", fmt.Sprint(l.Value), l.Lang)
+	case l.Datatype != "":
+		return fmt.Sprintf("%q^^%s", fmt.Sprint(l.Value), l.Datatype)
+	default:
+		return fmt.Sprint(l.Value)
+	}
+}
+
+// formatLiteral writes l as its language and datatype, each followed by a zero
+// byte, then its formatted value. So literals are ordered by language, then
+// datatype, then value.
+func (t *Typer) formatLiteral(l Literal) ([]byte, error) {
+	if _, ok := l.Value.(Literal); ok {
+		return nil, fmt.Errorf("no6: literal cannot have a literal value")
+	}
+	if strings.IndexByte(l.Lang, 0) >= 0 || strings.IndexByte(l.Datatype, 0) >= 0 {
+		return nil, fmt.Errorf("no6: literal language and datatype cannot contain zero bytes")
+	}
+
+	value, err := t.Format(l.Value)
+	if err != nil {
+		return nil, err
+	}
+	if l.Lang == "" && l.Datatype == "" {
+		return value, nil
+	}
+
+	data := make([]byte, 0, 3+len(l.Lang)+len(l.Datatype)+len(value))
+	data = append(data, byte(TypeLiteral))
+	data = append(data, l.Lang...)
+	data = append(data, 0)
+	data = append(data, l.Datatype...)
+	data = append(data, 0)
+	return append(data, value...), nil
+}
+
+// splitLiteral returns the parts of the formatted literal in data.
+func splitLiteral(data []byte) (lang, datatype string, value []byte, err error) {
+	parts := bytes.SplitN(data[1:], []byte{0}, 3)
+	if len(parts) != 3 {
+		return "", "", nil, fmt.Errorf("no6: literal is missing its language or datatype")
+	}
+
+	return string(parts[0]), string(parts[1]), parts[2], nil
+}
+
+func (t *Typer) readLiteral(data []byte) (Literal, error) {
+	lang, datatype, valueData, err := splitLiteral(data)
+	if err != nil {
+		return Literal{}, err
+	}
+
+	_, value, err := t.Read(valueData)
+	if err != nil {
+		return Literal{}, err
+	}
+
+	return Literal{Value: value, Lang: lang, Datatype: datatype}, nil
+}
+
+// textOf returns the string in the formatted object data, if it is a string or
+// a literal with a string value.
+func textOf(data []byte) (string, bool) {
+	if len(data) == 0 {
+		return "", false
+	}
+
+	if Type(data[0]) == TypeLiteral {
+		_, _, value, err := splitLiteral(data)
+		if err != nil {
+			return "", false
+		}
+		data = value
+	}
+
+	if len(data) == 0 || Type(data[0]) != TypeString {
+		return "", false
+	}
+
+	return string(data[1:]), true
+}
+
+type LangMatcher struct {
+	tags []string
+}
+
+// Lang returns a matcher that chooses, for each subject and predicate, the
+// values in the first of tags that there are any values for. A tag also
+// chooses more specific languages, so "en" chooses "en-GB". If there are no
+// values for any of tags then values without a language are chosen, unless
+// "*" is given as the last tag, in which case all values are.
+func Lang(tags ...string) LangMatcher {
+	return LangMatcher{tags: tags}
+}
+
+func (q LangMatcher) isMatcher() {}
+
+// langMatches returns true if lang is chosen by tag.
+func langMatches(tag, lang string) bool {
+	if tag == "*" {
+		return true
+	}
+
+	return strings.EqualFold(tag, lang) ||
+		len(lang) > len(tag) && lang[len(tag)] == '-' && strings.EqualFold(tag, lang[:len(tag)])
+}
+
+// chooseLang returns the triples, which share a subject and predicate, in the
+// first language of tags they have.
+func chooseLang(triples []Triple, tags []string) []Triple {
+	langOf := func(triple Triple) string {
+		if literal, ok := triple.Object.(Literal); ok {
+			return literal.Lang
+		}
+		return ""
+	}
+
+	for _, tag := range tags {
+		if tag == "*" {
+			return triples
+		}
+
+		var chosen []Triple
+		for _, triple := range triples {
+			if lang := langOf(triple); lang != "" && langMatches(tag, lang) {
+				chosen = append(chosen, triple)
+			}
+		}
+		if len(chosen) > 0 {
+			return chosen
+		}
+	}
+
+	var untagged []Triple
+	for _, triple := range triples {
+		if langOf(triple) == "" {
+			untagged = append(untagged, triple)
+		}
+	}
+
+	return untagged
+}
@@ -0,0 +1,126 @@
+package no6
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"hawx.me/code/assert"
+)
+
+func TestTyperLiteral(t *testing.T) {
+	typer := &Typer{}
+
+	for _, literal := range []Literal{
+		{Value: "Hello", Lang: "en"},
+		{Value: "Bonjour", Lang: "fr-CA"},
+		{Value: "2024-01-01", Datatype: "xsd:date"},
+		{Value: 5, Lang: "en", Datatype: "xsd:integer"},
+		{Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Datatype: "xsd:dateTime"},
+	} {
+		typ, read := mustRead(typer, mustFormat(typer, literal))
+		assert.Equal(t, TypeLiteral, typ)
+		assert.Equal(t, literal, read)
+	}
+
+	typ, read := mustRead(typer, mustFormat(typer, Literal{Value: "plain"}))
+	assert.Equal(t, TypeString, typ)
+	assert.Equal(t, "plain", read)
+
+	_, err := typer.Format(Literal{Value: Literal{Value: "nested", Lang: "en"}, Lang: "en"})
+	assert.NotNil(t, err)
+	_, err = typer.Format(Literal{Value: "zero", Lang: "en\x00"})
+	assert.NotNil(t, err)
+	_, err = typer.Format(Literal{Value: struct{}{}, Lang: "en"})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, -1, mustCompare(typer,
+		mustFormat(typer, Literal{Value: "b", Lang: "en"}),
+		mustFormat(typer, Literal{Value: "a", Lang: "fr"})))
+	assert.Equal(t, -1, mustCompare(typer,
+		mustFormat(typer, Literal{Value: "a", Lang: "en"}),
+		mustFormat(typer, Literal{Value: "b", Lang: "en"})))
+}
+
+func TestLiteralString(t *testing.T) {
+	assert.Equal(t, `"Hello"@en`, Literal{Value: "Hello", Lang: "en"}.String())
+	assert.Equal(t, `"2024-01-01"^^xsd:date`, Literal{Value: "2024-01-01", Datatype: "xsd:date"}.String())
+	assert.Equal(t, "5", Literal{Value: 5}.String())
+}
+
+func TestLang(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"a", "label", Literal{Value: "Colour", Lang: "en-GB"}},
+		Triple{"a", "label", Literal{Value: "Color", Lang: "en-US"}},
+		Triple{"a", "label", Literal{Value: "Couleur", Lang: "fr"}},
+		Triple{"a", "label", "colour"},
+		Triple{"b", "label", Literal{Value: "Farbe", Lang: "de"}},
+		Triple{"b", "label", "farbe"},
+		Triple{"c", "label", Literal{Value: "Kleur", Lang: "nl"}},
+	))
+
+	t.Run("equal", func(t *testing.T) {
+		triples, err := store.Query(Predicates("label").Eq(Literal{Value: "Couleur", Lang: "fr"}))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"a", "label", Literal{Value: "Couleur", Lang: "fr"}}}, triples)
+
+		triples, err = store.Query(Predicates("label").Eq("Couleur"))
+		assert.Nil(t, err)
+		assert.Len(t, triples, 0)
+	})
+
+	t.Run("language", func(t *testing.T) {
+		triples, err := store.Query(Subjects("a"), Lang("fr"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"a", "label", Literal{Value: "Couleur", Lang: "fr"}}}, triples)
+
+		triples, err = store.Query(Subjects("a"), Lang("EN-us"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"a", "label", Literal{Value: "Color", Lang: "en-US"}}}, triples)
+	})
+
+	t.Run("more specific language", func(t *testing.T) {
+		triples, err := store.Query(Subjects("a"), Lang("en"))
+		assert.Nil(t, err)
+		assert.Len(t, triples, 2)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		triples, err := store.Query(Lang("fr", "de"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"a", "label", Literal{Value: "Couleur", Lang: "fr"}},
+			{"b", "label", Literal{Value: "Farbe", Lang: "de"}},
+		}, triples)
+
+		triples, err = store.Query(Lang("es"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"a", "label", "colour"},
+			{"b", "label", "farbe"},
+		}, triples)
+
+		triples, err = store.Query(Subjects("c"), Lang("es", "*"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"c", "label", Literal{Value: "Kleur", Lang: "nl"}}}, triples)
+	})
+
+	t.Run("match", func(t *testing.T) {
+		assert.Nil(t, store.SetIndexer("label", SearchIndexer{}))
+
+		subjects, err := store.QuerySubjects(Predicates("label").Match("couleur"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a"}, subjects)
+
+		triples, err := store.Query(Predicates("label").Match("kleur"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"c", "label", Literal{Value: "Kleur", Lang: "nl"}}}, triples)
+	})
+}
@@ -0,0 +1,315 @@
+package no6
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// formatVersion is the version of the on-disk format written by this package.
+// It is stored in the meta bucket, and databases written with an earlier
+// version are migrated when opened.
+//
+//	0: a single data bucket of (X, ID(X)) and (ID(X), X) pairs
+//	1: a dictionary of separate node and literal namespaces
+//	2: a reverse index from objects to subjects for each predicate
+//	3: negative ints are formatted so that their bytes sort in order
+const formatVersion = 3
+
+var (
+	// ErrMigrationRequired is returned when opening a database written in an
+	// older format as read-only, as it cannot be migrated.
+	ErrMigrationRequired = errors.New("no6: database is in an older format and must be opened for writing to migrate")
+
+	// ErrUnknownVersion is returned when opening a database written in a newer
+	// format than this package understands.
+	ErrUnknownVersion = errors.New("no6: database is in an unknown format")
+)
+
+// bucketData is the single dictionary bucket used in version 0.
+var bucketData = []byte("data")
+
+// bucketLiteralsMigrating holds the literals bucket while it is being rebuilt
+// for version 3.
+var bucketLiteralsMigrating = []byte("literals-migrating")
+
+func readVersion(tx *bbolt.Tx) uint64 {
+	if metaBucket := tx.Bucket(bucketMeta); metaBucket != nil {
+		if version := metaBucket.Get(keyVersion); version != nil {
+			return readUID(version)
+		}
+	}
+
+	if tx.Bucket(bucketData) != nil {
+		return 0
+	}
+
+	// nothing has been written yet
+	return formatVersion
+}
+
+func writeVersion(tx *bbolt.Tx) error {
+	metaBucket, err := tx.CreateBucketIfNotExists(bucketMeta)
+	if err != nil {
+		return err
+	}
+
+	return metaBucket.Put(keyVersion, writeUID(formatVersion))
+}
+
+// migrate brings the database up to formatVersion, or checks that it is
+// already when readOnly.
+func migrate(db *bbolt.DB, readOnly bool) error {
+	if readOnly {
+		return db.View(func(tx *bbolt.Tx) error {
+			switch version := readVersion(tx); {
+			case version > formatVersion:
+				return ErrUnknownVersion
+			case version < formatVersion:
+				return ErrMigrationRequired
+			}
+
+			return nil
+		})
+	}
+
+	var version uint64
+	if err := db.View(func(tx *bbolt.Tx) error {
+		version = readVersion(tx)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if version > formatVersion {
+		return ErrUnknownVersion
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		if version == formatVersion && tx.Bucket(bucketMeta) != nil {
+			return nil
+		}
+
+		if version < 1 {
+			if err := migrateDictionary(tx); err != nil {
+				return fmt.Errorf("no6: migrating to version 1: %w", err)
+			}
+		}
+		if version < 2 {
+			if err := migrateReverse(tx); err != nil {
+				return fmt.Errorf("no6: migrating to version 2: %w", err)
+			}
+		}
+		if version < 3 {
+			if err := migrateNegativeInts(tx); err != nil {
+				return fmt.Errorf("no6: migrating to version 3: %w", err)
+			}
+		}
+
+		return writeVersion(tx)
+	})
+}
+
+// migrateDictionary moves the entries in the data bucket into the namespaced
+// dictionary. The data bucket cannot tell subjects and objects apart, so the
+// posting lists are walked instead: the UIDs in keys are subjects and those in
+// lists are objects. UIDs are kept the same, so the posting lists only change
+// to remove any duplicate UIDs, which could be written by earlier versions, and
+// for nested objects.
+//
+// Before Ref existed x/micro stored a nested object as a string naming its
+// subject, which was resolved when read. So a string object naming a subject
+// that has a "type" is changed to a Ref to that subject, as x/micro now writes
+// them. Entries that are not used by any posting list are dropped.
+func migrateDictionary(tx *bbolt.Tx) error {
+	dataBucket := tx.Bucket(bucketData)
+
+	nodes, err := tx.CreateBucketIfNotExists(bucketNodes)
+	if err != nil {
+		return err
+	}
+	literals, err := tx.CreateBucketIfNotExists(bucketLiterals)
+	if err != nil {
+		return err
+	}
+	values, err := tx.CreateBucketIfNotExists(bucketValues)
+	if err != nil {
+		return err
+	}
+
+	add := func(forward *bbolt.Bucket, ns namespace, uid []byte) error {
+		if values.Get(uid) != nil {
+			return nil
+		}
+
+		data := dataBucket.Get(uid)
+		if data == nil {
+			return fmt.Errorf("no value for uid %d", readUID(uid))
+		}
+
+		if err := forward.Put(data, uid); err != nil {
+			return err
+		}
+		return values.Put(uid, append([]byte{byte(ns)}, data...))
+	}
+
+	if predicatesBucket := tx.Bucket(bucketPredicates); predicatesBucket != nil {
+		if err := predicatesBucket.ForEach(func(p, _ []byte) error {
+			predicateBucket := tx.Bucket([]byte("predicate-" + string(p)))
+			if predicateBucket == nil {
+				return nil
+			}
+
+			// buckets can't be changed while iterating, so collect the changed lists
+			// to write after
+			changed := map[string][]byte{}
+
+			if err := predicateBucket.ForEach(func(k, v []byte) error {
+				if err := add(nodes, namespaceNode, k[:8]); err != nil {
+					return err
+				}
+
+				objects := readList(v)
+				for i, object := range objects {
+					uid := writeUID(object)
+
+					if subjectUID := nestedSubject(tx, dataBucket, uid); subjectUID != nil {
+						if err := add(nodes, namespaceNode, subjectUID); err != nil {
+							return err
+						}
+						objects[i] = readUID(subjectUID)
+						continue
+					}
+
+					if err := add(literals, namespaceLiteral, uid); err != nil {
+						return err
+					}
+				}
+
+				if list := makeValue(sortUnique(objects)); !bytes.Equal(list, v) {
+					changed[string(k)] = list
+				}
+
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			for k, list := range changed {
+				if err := predicateBucket.Put([]byte(k), list); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.DeleteBucket(bucketData)
+}
+
+// nestedSubject returns the UID of the subject named by the object with uid,
+// if the object is a string and that subject has a "type".
+func nestedSubject(tx *bbolt.Tx, dataBucket *bbolt.Bucket, uid []byte) []byte {
+	data := dataBucket.Get(uid)
+	if len(data) == 0 || Type(data[0]) != TypeString {
+		return nil
+	}
+
+	subjectUID := dataBucket.Get(data[1:])
+	if subjectUID == nil {
+		return nil
+	}
+
+	typeBucket := tx.Bucket([]byte("predicate-type"))
+	if typeBucket == nil || typeBucket.Get(makeKey(readUID(subjectUID), "type")) == nil {
+		return nil
+	}
+
+	return subjectUID
+}
+
+// migrateReverse builds the reverse-* bucket for each predicate from its
+// posting lists.
+func migrateReverse(tx *bbolt.Tx) error {
+	predicatesBucket := tx.Bucket(bucketPredicates)
+	if predicatesBucket == nil {
+		return nil
+	}
+
+	return predicatesBucket.ForEach(func(p, _ []byte) error {
+		predicateBucket := tx.Bucket([]byte("predicate-" + string(p)))
+		if predicateBucket == nil {
+			return nil
+		}
+
+		return predicateBucket.ForEach(func(k, v []byte) error {
+			for i := 0; i < len(v); i += 8 {
+				if err := addReverse(tx, string(p), v[i:i+8], k[:8]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// migrateNegativeInts rewrites the dictionary entries for negative ints, which
+// were previously formatted with their magnitude instead of its inverse. The
+// UIDs do not change so posting lists are untouched.
+//
+// The new format of one value can be the old format of another, for example
+// math.MinInt64 becomes what math.MinInt64+1 was, so the literals bucket is
+// built again in a new bucket rather than rewriting its keys in place.
+func migrateNegativeInts(tx *bbolt.Tx) error {
+	literals := tx.Bucket(bucketLiterals)
+	values := tx.Bucket(bucketValues)
+	if literals == nil || values == nil {
+		return nil
+	}
+
+	migrating, err := tx.CreateBucket(bucketLiteralsMigrating)
+	if err != nil {
+		return err
+	}
+
+	if err := literals.ForEach(func(k, uid []byte) error {
+		if len(k) != 10 || Type(k[0]) != TypeInt || k[1] != 0 {
+			return migrating.Put(k, uid)
+		}
+
+		to := bytes.Clone(k)
+		for i := 2; i < len(to); i++ {
+			to[i] = ^to[i]
+		}
+
+		if err := migrating.Put(to, uid); err != nil {
+			return err
+		}
+		return values.Put(uid, append([]byte{byte(namespaceLiteral)}, to...))
+	}); err != nil {
+		return err
+	}
+
+	// bbolt can't rename buckets, so the new bucket is copied into place
+	if err := tx.DeleteBucket(bucketLiterals); err != nil {
+		return err
+	}
+	literals, err = tx.CreateBucket(bucketLiterals)
+	if err != nil {
+		return err
+	}
+
+	if err := migrating.ForEach(func(k, uid []byte) error {
+		return literals.Put(k, uid)
+	}); err != nil {
+		return err
+	}
+
+	return tx.DeleteBucket(bucketLiteralsMigrating)
+}
@@ -0,0 +1,319 @@
+package no6
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestMigrateDictionary(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// write the version 0 layout for (john, knows, "dave") and (dave, age, 30),
+	// with an unused entry for "mike"
+	db, _ := bbolt.Open(file.Name(), 0600, nil)
+	typer := &Typer{}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		idBucket, _ := tx.CreateBucket(bucketID)
+		idBucket.Put(keyLast, writeUID(5))
+
+		dataBucket, _ := tx.CreateBucket(bucketData)
+		for uid, value := range map[uint64][]byte{
+			1: []byte("john"),
+			2: mustFormat(typer, "dave"),
+			3: []byte("dave"),
+			4: mustFormat(typer, 30),
+			5: mustFormat(typer, "mike"),
+		} {
+			dataBucket.Put(writeUID(uid), value)
+			dataBucket.Put(value, writeUID(uid))
+		}
+
+		predicatesBucket, _ := tx.CreateBucket(bucketPredicates)
+		predicatesBucket.Put([]byte("knows"), []byte{})
+		predicatesBucket.Put([]byte("age"), []byte{})
+
+		knowsBucket, _ := tx.CreateBucket([]byte("predicate-knows"))
+		knowsBucket.Put(makeKey(1, "knows"), makeValue([]uint64{2}))
+		ageBucket, _ := tx.CreateBucket([]byte("predicate-age"))
+		ageBucket.Put(makeKey(3, "age"), makeValue([]uint64{4}))
+
+		return nil
+	})
+	assert.Nil(t, err)
+	db.Close()
+
+	_, err = OpenWithOptions(file.Name(), Options{ReadOnly: true})
+	assert.Equal(t, ErrMigrationRequired, err)
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+
+	triples, err := store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"dave", "age", 30}, {"john", "knows", "dave"}},
+		triples,
+	)
+
+	subjects, err := store.QuerySubjects(Predicates("knows").Eq("dave"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"john"}, subjects)
+
+	assert.Nil(t, store.Put("mike", "age", 25))
+
+	subjects, err = store.QuerySubjects(Predicates("age").Lt(30))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mike"}, subjects)
+
+	store.db.View(func(tx *bbolt.Tx) error {
+		assert.Nil(t, tx.Bucket(bucketData))
+		assert.Equal(t, uint64(formatVersion), readVersion(tx))
+		// "mike" was unused so dropped, then mike the subject and 25 were added
+		assert.Equal(t, uint64(7), readUID(tx.Bucket(bucketID).Get(keyLast)))
+		assert.Nil(t, tx.Bucket(bucketLiterals).Get(mustFormat(typer, "mike")))
+		return nil
+	})
+
+	store.Close()
+
+	reader, err := OpenWithOptions(file.Name(), Options{ReadOnly: true})
+	assert.Nil(t, err)
+	reader.Close()
+}
+
+func TestMigrateDictionaryDuplicates(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// write the version 0 layout for (john, knows, "dave") with "dave" listed
+	// twice
+	db, _ := bbolt.Open(file.Name(), 0600, nil)
+	typer := &Typer{}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		idBucket, _ := tx.CreateBucket(bucketID)
+		idBucket.Put(keyLast, writeUID(2))
+
+		dataBucket, _ := tx.CreateBucket(bucketData)
+		for uid, value := range map[uint64][]byte{
+			1: []byte("john"),
+			2: mustFormat(typer, "dave"),
+		} {
+			dataBucket.Put(writeUID(uid), value)
+			dataBucket.Put(value, writeUID(uid))
+		}
+
+		predicatesBucket, _ := tx.CreateBucket(bucketPredicates)
+		predicatesBucket.Put([]byte("knows"), []byte{})
+
+		knowsBucket, _ := tx.CreateBucket([]byte("predicate-knows"))
+		knowsBucket.Put(makeKey(1, "knows"), makeValue([]uint64{2, 2}))
+
+		return nil
+	})
+	assert.Nil(t, err)
+	db.Close()
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	triples, err := store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"john", "knows", "dave"}}, triples)
+
+	assert.Nil(t, store.DeleteTriple("john", "knows", "dave"))
+
+	triples, err = store.Query()
+	assert.Nil(t, err)
+	assert.Len(t, triples, 0)
+
+	subjects, err := store.QuerySubjects(Predicates("knows").Eq("dave"))
+	assert.Nil(t, err)
+	assert.Len(t, subjects, 0)
+}
+
+func TestMigrateDictionaryNestedObjects(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// write the version 0 layout that x/micro used for an entry with a nested
+	// card, (entry, location, "card") and (card, type, "h-card"), and with
+	// (entry, mention, "other") where other has no type
+	db, _ := bbolt.Open(file.Name(), 0600, nil)
+	typer := &Typer{}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		idBucket, _ := tx.CreateBucket(bucketID)
+		idBucket.Put(keyLast, writeUID(7))
+
+		dataBucket, _ := tx.CreateBucket(bucketData)
+		for uid, value := range map[uint64][]byte{
+			1: []byte("entry"),
+			2: mustFormat(typer, "card"),
+			3: []byte("card"),
+			4: mustFormat(typer, "h-card"),
+			5: mustFormat(typer, "other"),
+			6: []byte("other"),
+			7: mustFormat(typer, "Other"),
+		} {
+			dataBucket.Put(writeUID(uid), value)
+			dataBucket.Put(value, writeUID(uid))
+		}
+
+		predicatesBucket, _ := tx.CreateBucket(bucketPredicates)
+		for _, predicate := range []string{"location", "type", "mention", "name"} {
+			predicatesBucket.Put([]byte(predicate), []byte{})
+		}
+
+		locationBucket, _ := tx.CreateBucket([]byte("predicate-location"))
+		locationBucket.Put(makeKey(1, "location"), makeValue([]uint64{2}))
+		typeBucket, _ := tx.CreateBucket([]byte("predicate-type"))
+		typeBucket.Put(makeKey(3, "type"), makeValue([]uint64{4}))
+		mentionBucket, _ := tx.CreateBucket([]byte("predicate-mention"))
+		mentionBucket.Put(makeKey(1, "mention"), makeValue([]uint64{5}))
+		nameBucket, _ := tx.CreateBucket([]byte("predicate-name"))
+		nameBucket.Put(makeKey(6, "name"), makeValue([]uint64{7}))
+
+		return nil
+	})
+	assert.Nil(t, err)
+	db.Close()
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	triples, err := store.Query(Subjects("entry"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{
+		{"entry", "location", Ref("card")},
+		{"entry", "mention", "other"},
+	}, triples)
+
+	subjects, err := store.QuerySubjects(ReferencedBy("card", "location"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"entry"}, subjects)
+
+	store.db.View(func(tx *bbolt.Tx) error {
+		// "card" is no longer used as a string so was dropped
+		assert.Nil(t, tx.Bucket(bucketLiterals).Get(mustFormat(typer, "card")))
+		return nil
+	})
+}
+
+func TestDictionaryNamespaces(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	// a subject that looks like the UID 1
+	collider := string(writeUID(1))
+
+	store.PutTriples(
+		Triple{"x", "name", "x"},
+		Triple{collider, "name", "collider"},
+		Triple{"y", "name", "x"},
+	)
+
+	triples, err := store.Query(Predicates("name"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"x", "name", "x"}, {collider, "name", "collider"}, {"y", "name", "x"}},
+		triples,
+	)
+
+	store.db.View(func(tx *bbolt.Tx) error {
+		dict := store.readDictionary(tx)
+		assert.Equal(t, writeUID(1), dict.nodeUID("x"))
+		assert.Equal(t, writeUID(2), dict.literalUID(mustFormat(store.typer, "x")))
+		return nil
+	})
+}
+
+func TestMigrateNegativeInts(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	store.PutTriples(
+		Triple{"a", "size", 5},
+		Triple{"b", "size", -5},
+		Triple{"c", "size", -1000},
+		Triple{"d", "size", math.MinInt64},
+		Triple{"e", "size", math.MinInt64 + 1},
+		Triple{"f", "size", -1},
+		Triple{"g", "size", math.MaxInt64},
+	)
+
+	// put back the version 2 format for negative ints, which stored the
+	// magnitude instead of its inverse; as old and new formats can be the same
+	// for different values they are all removed before any are put back
+	store.db.Update(func(tx *bbolt.Tx) error {
+		negatives := []int{-5, -1000, math.MinInt64, math.MinInt64 + 1, -1}
+
+		uids := make([][]byte, len(negatives))
+		for i, n := range negatives {
+			data := mustFormat(store.typer, n)
+			uids[i] = bytes.Clone(tx.Bucket(bucketLiterals).Get(data))
+			tx.Bucket(bucketLiterals).Delete(data)
+		}
+
+		for i, n := range negatives {
+			old := mustFormat(store.typer, n)
+			binary.BigEndian.PutUint64(old[2:], uint64(-n))
+
+			tx.Bucket(bucketLiterals).Put(old, uids[i])
+			tx.Bucket(bucketValues).Put(uids[i], append([]byte{byte(namespaceLiteral)}, old...))
+		}
+
+		return tx.Bucket(bucketMeta).Put(keyVersion, writeUID(2))
+	})
+	store.Close()
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+
+	triples, err := store.Query(Predicates("size"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{
+		{"a", "size", 5},
+		{"b", "size", -5},
+		{"c", "size", -1000},
+		{"d", "size", math.MinInt64},
+		{"e", "size", math.MinInt64 + 1},
+		{"f", "size", -1},
+		{"g", "size", math.MaxInt64},
+	}, triples)
+
+	for subject, n := range map[string]int{
+		"b": -5,
+		"d": math.MinInt64,
+		"e": math.MinInt64 + 1,
+		"f": -1,
+	} {
+		subjects, err := store.QuerySubjects(Predicates("size").Eq(n))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{subject}, subjects)
+	}
+
+	subjects, err := store.QuerySubjects(Predicates("size"), Sort("size"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "e", "c", "b", "f", "a", "g"}, subjects)
+
+	store.db.View(func(tx *bbolt.Tx) error {
+		assert.Nil(t, tx.Bucket(bucketLiteralsMigrating))
+		return nil
+	})
+}
@@ -0,0 +1,142 @@
+package no6
+
+import (
+	"bytes"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+// The order-* bucket for a predicate, if it exists, contains
+// (sortKey(Format(object)), subjects) pairs, where subjects is a posting list
+// of every subject that has the object as a value for the predicate. As the
+// keys are sorted in the order of Typer.Compare, ranges and sorted results can
+// be read with a cursor instead of comparing every value.
+//
+// It is optional as it duplicates all of the values for the predicate, so is
+// only kept for predicates using the FullTextIndexer.
+
+func orderBucketName(predicate string) []byte {
+	return []byte("order-" + predicate)
+}
+
+// addOrder records that subjectUID has the formatted object data for
+// predicate, if the predicate is being indexed.
+func addOrder(tx *bbolt.Tx, predicate string, data, subjectUID []byte) error {
+	orderBucket := tx.Bucket(orderBucketName(predicate))
+	if orderBucket == nil {
+		return nil
+	}
+
+	key := sortKey(data)
+	subjectList := orderBucket.Get(key)
+	updatedList := appendValue(subjectList, readUID(subjectUID))
+	if len(updatedList) == len(subjectList) {
+		return nil
+	}
+
+	return orderBucket.Put(key, updatedList)
+}
+
+// removeOrder records that subjectUID no longer has any of the objects in
+// objectList for predicate, if the predicate is being indexed.
+func removeOrder(tx *bbolt.Tx, dict *dictionary, predicate string, objectList, subjectUID []byte) error {
+	orderBucket := tx.Bucket(orderBucketName(predicate))
+	if orderBucket == nil {
+		return nil
+	}
+
+	for i := 0; i < len(objectList); i += 8 {
+		key := sortKey(dict.literal(objectList[i : i+8]))
+
+		subjectList := orderBucket.Get(key)
+		updatedList := removeValue(subjectList, readUID(subjectUID))
+		if len(updatedList) == len(subjectList) {
+			continue
+		}
+
+		if len(updatedList) == 0 {
+			if err := orderBucket.Delete(key); err != nil {
+				return err
+			}
+		} else if err := orderBucket.Put(key, updatedList); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subjectsInRange returns the subjects with a value that is less than data
+// (for Lt), or greater than it (for Gt). Values of other types are compared by
+// the collation described on Typer.Compare, which is the order of their keys.
+func subjectsInRange(orderBucket *bbolt.Bucket, constraint Constraint, data []byte) []uint64 {
+	var subjects []uint64
+	c := orderBucket.Cursor()
+	data = sortKey(data)
+
+	switch constraint {
+	case Lt:
+		for k, v := c.First(); k != nil && bytes.Compare(k, data) < 0; k, v = c.Next() {
+			subjects = append(subjects, readList(v)...)
+		}
+	case Gt:
+		k, v := c.Seek(data)
+		if bytes.Equal(k, data) {
+			k, v = c.Next()
+		}
+		for ; k != nil; k, v = c.Next() {
+			subjects = append(subjects, readList(v)...)
+		}
+	}
+
+	return sortUnique(subjects)
+}
+
+// sortByIndex returns the sorted subjects by reading the index in order.
+// Subjects without a value are placed at the end. If limit is not 0 it stops
+// once that many subjects have been found.
+func sortByIndex(orderBucket *bbolt.Bucket, subjects []uint64, desc bool, limit uint) []uint64 {
+	if limit == 0 || int(limit) > len(subjects) {
+		limit = uint(len(subjects))
+	}
+
+	sorted := make([]uint64, 0, limit)
+	seen := make(map[uint64]struct{}, limit)
+
+	c := orderBucket.Cursor()
+	first, next := c.First, c.Next
+	if desc {
+		first, next = c.Last, c.Prev
+	}
+
+	for k, v := first(); k != nil && len(sorted) < int(limit); k, v = next() {
+		for i := 0; i < len(v) && len(sorted) < int(limit); i += 8 {
+			subject := readUID(v[i : i+8])
+
+			idx := sort.Search(len(subjects), func(j int) bool {
+				return subjects[j] >= subject
+			})
+			if idx == len(subjects) || subjects[idx] != subject {
+				continue
+			}
+			if _, ok := seen[subject]; ok {
+				continue
+			}
+
+			seen[subject] = struct{}{}
+			sorted = append(sorted, subject)
+		}
+	}
+
+	for _, subject := range subjects {
+		if len(sorted) == int(limit) {
+			break
+		}
+		if _, ok := seen[subject]; !ok {
+			sorted = append(sorted, subject)
+		}
+	}
+
+	return sorted
+}
@@ -0,0 +1,337 @@
+package no6
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestOrderIndex(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file, _ := os.CreateTemp("", "")
+			file.Close()
+			defer os.Remove(file.Name())
+
+			store, _ := Open(file.Name())
+
+			if indexed {
+				assert.Nil(t, store.SetIndexer("size", FullTextIndexer{}))
+				assert.Nil(t, store.SetIndexer("colour", FullTextIndexer{}))
+			}
+
+			store.PutTriples(
+				Triple{"a", "size", 1},
+				Triple{"b", "size", 4},
+				Triple{"c", "size", -2},
+				Triple{"d", "size", 5},
+				Triple{"e", "size", -30},
+				Triple{"f", "size", 3},
+				Triple{"f", "size", 10},
+				Triple{"h", "colour", "red"},
+			)
+
+			t.Run("Lt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("size").Lt(3))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c", "e"}, subjects)
+			})
+
+			t.Run("Gt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("size").Gt(3))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"b", "d", "f"}, subjects)
+			})
+
+			t.Run("Gt and Lt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Gt(-5),
+					Predicates("size").Lt(4),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c", "f"}, subjects)
+			})
+
+			t.Run("Sort", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Lt(100),
+					Sort("size"),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"e", "c", "a", "f", "b", "d"}, subjects)
+			})
+
+			t.Run("Sort desc", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Lt(100),
+					Sort("size").Desc(),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"f", "d", "b", "a", "c", "e"}, subjects)
+			})
+
+			t.Run("Sort limit", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Lt(100),
+					Sort("size"),
+					Limit(2),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"e", "c"}, subjects)
+			})
+
+			t.Run("Sort missing values", func(t *testing.T) {
+				store.Put("h", "size", 2)
+				defer store.Delete("h", "size")
+
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Lt(3),
+					Sort("colour"),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"h", "a", "c", "e"}, subjects)
+
+				subjects, err = store.QuerySubjects(
+					Predicates("size").Lt(3),
+					Sort("colour").Desc(),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"h", "a", "c", "e"}, subjects)
+			})
+
+			t.Run("Limit beyond results", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(
+					Predicates("size").Lt(-3),
+					Sort("size"),
+					Limit(10),
+				)
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"e"}, subjects)
+			})
+		})
+	}
+}
+
+func TestOrderIndexMaintained(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(
+		Triple{"a", "size", 1},
+		Triple{"a", "size", 2},
+		Triple{"b", "size", 2},
+		Triple{"c", "size", 3},
+	)
+	assert.Nil(t, store.SetIndexer("size", FullTextIndexer{}))
+
+	indexed := func() map[int][]uint64 {
+		result := map[int][]uint64{}
+		store.db.View(func(tx *bbolt.Tx) error {
+			orderBucket := tx.Bucket(orderBucketName("size"))
+			for _, size := range []int{1, 2, 3} {
+				data, _ := store.typer.Format(size)
+				if v := orderBucket.Get(sortKey(data)); v != nil {
+					result[size] = readList(v)
+				}
+			}
+			return nil
+		})
+		return result
+	}
+
+	// a=1, "1"=2, "2"=3, b=4, c=5, "3"=6
+	assert.Equal(t, map[int][]uint64{1: {1}, 2: {1, 4}, 3: {5}}, indexed())
+
+	store.DeleteTriple("a", "size", 2)
+	assert.Equal(t, map[int][]uint64{1: {1}, 2: {4}, 3: {5}}, indexed())
+
+	store.Delete("b", "size")
+	assert.Equal(t, map[int][]uint64{1: {1}, 3: {5}}, indexed())
+
+	store.DeleteSubject("c")
+	assert.Equal(t, map[int][]uint64{1: {1}}, indexed())
+
+	assert.Nil(t, store.SetIndexer("size", nil))
+
+	subjects, err := store.QuerySubjects(Predicates("size").Lt(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, subjects)
+}
+
+func TestOrderTypes(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file, _ := os.CreateTemp("", "")
+			file.Close()
+			defer os.Remove(file.Name())
+
+			store, _ := Open(file.Name())
+			defer store.Close()
+
+			if indexed {
+				assert.Nil(t, store.SetIndexer("price", FullTextIndexer{}))
+				assert.Nil(t, store.SetIndexer("published", FullTextIndexer{}))
+			}
+
+			store.PutTriples(
+				Triple{"a", "price", 2.5},
+				Triple{"b", "price", -0.5},
+				Triple{"c", "price", 10.0},
+				Triple{"a", "published", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+				Triple{"b", "published", time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)},
+				Triple{"c", "published", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			)
+
+			subjects, err := store.QuerySubjects(Predicates("price").Lt(3.0), Sort("price"))
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"b", "a"}, subjects)
+
+			subjects, err = store.QuerySubjects(Predicates("price").Gt(0.0), Sort("price").Desc())
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"c", "a"}, subjects)
+
+			subjects, err = store.QuerySubjects(
+				Predicates("published").Gt(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+				Sort("published"),
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"a", "c"}, subjects)
+
+			subjects, err = store.QuerySubjects(Predicates("published"), Sort("published").Desc())
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"c", "a", "b"}, subjects)
+		})
+	}
+}
+
+func TestOrderMixedTypes(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file, _ := os.CreateTemp("", "")
+			file.Close()
+			defer os.Remove(file.Name())
+
+			store, _ := Open(file.Name())
+			defer store.Close()
+
+			if indexed {
+				assert.Nil(t, store.SetIndexer("age", FullTextIndexer{}))
+			}
+
+			store.PutTriples(
+				Triple{"a", "age", 30},
+				Triple{"b", "age", "20"},
+				Triple{"c", "age", 20.5},
+				Triple{"d", "age", false},
+				Triple{"e", "age", 10},
+				Triple{"f", "age", "unknown"},
+			)
+
+			t.Run("Sort", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("age"), Sort("age"))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"b", "f", "d", "e", "c", "a"}, subjects)
+
+				subjects, err = store.QuerySubjects(Predicates("age"), Sort("age").Desc())
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c", "e", "d", "f", "b"}, subjects)
+			})
+
+			t.Run("Lt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("age").Lt(18))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"b", "d", "e", "f"}, subjects)
+
+				triples, err := store.Query(Predicates("age").Lt(18))
+				assert.Nil(t, err)
+				assert.Len(t, triples, 4)
+			})
+
+			t.Run("Gt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("age").Gt(18))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c"}, subjects)
+
+				subjects, err = store.QuerySubjects(Predicates("age").Gt("a"))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c", "d", "e", "f"}, subjects)
+			})
+		})
+	}
+}
+
+func TestOrderMixedNumbers(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file, _ := os.CreateTemp("", "")
+			file.Close()
+			defer os.Remove(file.Name())
+
+			store, _ := Open(file.Name())
+			defer store.Close()
+
+			if indexed {
+				assert.Nil(t, store.SetIndexer("price", FullTextIndexer{}))
+			}
+
+			store.PutTriples(
+				Triple{"a", "price", 3.5},
+				Triple{"b", "price", 10},
+				Triple{"c", "price", uint(2)},
+				Triple{"d", "price", 4},
+			)
+
+			t.Run("Sort", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("price"), Sort("price"))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"c", "a", "d", "b"}, subjects)
+
+				subjects, err = store.QuerySubjects(Predicates("price"), Sort("price").Desc())
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"b", "d", "a", "c"}, subjects)
+			})
+
+			t.Run("Lt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("price").Lt(5))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "c", "d"}, subjects)
+			})
+
+			t.Run("Gt", func(t *testing.T) {
+				subjects, err := store.QuerySubjects(Predicates("price").Gt(uint(3)))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "b", "d"}, subjects)
+
+				subjects, err = store.QuerySubjects(Predicates("price").Gt(3.5))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"b", "d"}, subjects)
+			})
+		})
+	}
+}
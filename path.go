@@ -0,0 +1,125 @@
+package no6
+
+import (
+	"errors"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+type PathMatcher struct {
+	path []string
+	//
+	constraint Constraint
+	object     any
+}
+
+// Path returns a matcher that follows Ref objects through each predicate in
+// turn, matching subjects that reach a node with a value for the last. So
+//
+//	Path("author", "name").Eq("Alice")
+//
+// matches subjects with an author whose name is Alice. When used with Query the
+// triples returned are those for the first predicate that lead to a match.
+func Path(predicates ...string) PathMatcher {
+	return PathMatcher{path: predicates}
+}
+
+func (q PathMatcher) isMatcher()        {}
+func (q PathMatcher) isSubjectMatcher() {}
+
+// Eq returns a matcher that matches paths ending with an equal object.
+func (q PathMatcher) Eq(object any) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Eq, object: object}
+}
+
+func (q PathMatcher) Ne(object any) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Ne, object: object}
+}
+
+func (q PathMatcher) Lt(object any) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Lt, object: object}
+}
+
+func (q PathMatcher) Gt(object any) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Gt, object: object}
+}
+
+// Match returns a matcher that matches paths ending with an object containing
+// every term in query. The last predicate must use a SearchIndexer.
+func (q PathMatcher) Match(query string) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Match, object: query}
+}
+
+// Within returns a matcher that matches paths ending with a Point object inside
+// box.
+func (q PathMatcher) Within(box Box) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Within, object: box}
+}
+
+// Near returns a matcher that matches paths ending with a Point object within
+// radius metres of point.
+func (q PathMatcher) Near(point Point, radius float64) PathMatcher {
+	return PathMatcher{path: q.path, constraint: Near, object: nearObject{point: point, radius: radius}}
+}
+
+// term returns the predicates and constraint of the path to be queried.
+func (q PathMatcher) term() (pathTerm, error) {
+	if len(q.path) == 0 {
+		return pathTerm{}, errors.New("no6: path must have at least one predicate")
+	}
+
+	term := pathTerm{path: q.path}
+	if q.object != nil {
+		term.constraint = &constraintObject{
+			constraint: q.constraint,
+			object:     q.object,
+		}
+	}
+
+	return term, nil
+}
+
+type pathTerm struct {
+	path       []string
+	constraint *constraintObject
+	indexer    Indexer
+}
+
+// last returns the predicate at the end of the path.
+func (p pathTerm) last() string {
+	return p.path[len(p.path)-1]
+}
+
+// pathSubjects returns the subjects at the start of path that lead to a value
+// for its last predicate meeting its constraint.
+func (s *Store) pathSubjects(tx *bbolt.Tx, dict *dictionary, path pathTerm) ([]uint64, error) {
+	subjects, _, err := s.subjectsMatching(tx, dict, path.last(), path.constraint, path.indexer)
+	if err != nil {
+		return nil, err
+	}
+
+	// refs are stored as the UID of the subject they refer to, so each step back
+	// is finding the subjects with one of the current subjects as a value
+	for i := len(path.path) - 2; i >= 0 && len(subjects) > 0; i-- {
+		var linked []uint64
+		for _, subject := range subjects {
+			linked = append(linked, subjectsWith(tx, path.path[i], writeUID(subject))...)
+		}
+
+		subjects = sortUnique(linked)
+	}
+
+	return subjects, nil
+}
+
+// linkedToAll returns true if uid is in every one of the sorted lists.
+func linkedToAll(lists [][]uint64, uid uint64) bool {
+	for _, list := range lists {
+		if _, ok := slices.BinarySearch(list, uid); !ok {
+			return false
+		}
+	}
+
+	return true
+}
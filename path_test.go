@@ -0,0 +1,94 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"hawx.me/code/assert"
+)
+
+func TestPath(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"alice", "name", "Alice"},
+		Triple{"alice", "age", 31},
+		Triple{"alice", "employer", Ref("acme")},
+		Triple{"bob", "name", "Bob"},
+		Triple{"bob", "age", 25},
+		Triple{"acme", "name", "Acme"},
+		Triple{"entry-1", "author", Ref("alice")},
+		Triple{"entry-1", "type", "h-entry"},
+		Triple{"entry-2", "author", Ref("bob")},
+		Triple{"entry-2", "type", "h-entry"},
+		Triple{"entry-3", "author", Ref("alice")},
+		Triple{"entry-3", "author", Ref("bob")},
+		Triple{"entry-3", "type", "h-note"},
+		Triple{"entry-4", "author", "Alice"},
+		Triple{"entry-4", "type", "h-entry"},
+	))
+
+	t.Run("QuerySubjects", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Path("author", "name").Eq("Alice"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"entry-1", "entry-3"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("author", "name").Eq("Alice"), Predicates("type").Eq("h-entry"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"entry-1"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("author", "age").Lt(30))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"entry-2", "entry-3"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("author", "employer", "name").Eq("Acme"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"entry-1", "entry-3"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("author", "employer"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"entry-1", "entry-3"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("name").Eq("Bob"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bob"}, subjects)
+
+		subjects, err = store.QuerySubjects(Path("author", "name").Eq("Carol"))
+		assert.Nil(t, err)
+		assert.Len(t, subjects, 0)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		triples, err := store.Query(Path("author", "name").Eq("Bob"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"entry-2", "author", Ref("bob")},
+			{"entry-3", "author", Ref("bob")},
+		}, triples)
+
+		triples, err = store.Query(Subjects("entry-3"), Path("author", "age").Gt(30))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"entry-3", "author", Ref("alice")}}, triples)
+
+		triples, err = store.Query(Path("name").Eq("Acme"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"acme", "name", "Acme"}}, triples)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := store.QuerySubjects(Path())
+		assert.NotNil(t, err)
+
+		_, err = store.Query(Path())
+		assert.NotNil(t, err)
+
+		_, err = store.QuerySubjects(Path("author", "name").Match("alice"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+}
@@ -0,0 +1,81 @@
+package no6
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Pred is a predicate whose objects are all of type T, so that they can be
+// read and written without type assertions. So
+//
+//	name := Pred[string]("name")
+//	names, err := name.Get(store, "alice")
+//
+// gives the names of "alice" as a []string. T should be one of the types the
+// typer understands, such as string, int, time.Time or Ref.
+type Pred[T any] string
+
+// querier is implemented by Store and Tx.
+type querier interface {
+	Query(matchers ...Matcher) ([]Triple, error)
+}
+
+// putter is implemented by Store and Tx.
+type putter interface {
+	Put(subject, predicate string, object any) error
+}
+
+// Get returns the objects of the predicate for subject, reading from either a
+// Store or a Tx. If any object is not a T an error wrapping ErrTypeMismatch is
+// returned.
+func (p Pred[T]) Get(q querier, subject string) ([]T, error) {
+	triples, err := q.Query(Subjects(subject), Predicates(string(p)))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]T, 0, len(triples))
+	for _, triple := range triples {
+		object, ok := p.Object(triple)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q of %q is %T, not %v", ErrTypeMismatch, string(p), subject, triple.Object, reflect.TypeFor[T]())
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// Put adds a triple with the predicate to either a Store or a Tx.
+func (p Pred[T]) Put(w putter, subject string, object T) error {
+	return w.Put(subject, string(p), object)
+}
+
+// Object returns the object of triple, if it is for the predicate and a T.
+func (p Pred[T]) Object(triple Triple) (T, bool) {
+	if triple.Predicate != string(p) {
+		var zero T
+		return zero, false
+	}
+
+	object, ok := triple.Object.(T)
+	return object, ok
+}
+
+// Eq returns a matcher that matches triples with the predicate and equal object.
+func (p Pred[T]) Eq(object T) PredicatesMatcher {
+	return Predicates(string(p)).Eq(object)
+}
+
+func (p Pred[T]) Ne(object T) PredicatesMatcher {
+	return Predicates(string(p)).Ne(object)
+}
+
+func (p Pred[T]) Lt(object T) PredicatesMatcher {
+	return Predicates(string(p)).Lt(object)
+}
+
+func (p Pred[T]) Gt(object T) PredicatesMatcher {
+	return Predicates(string(p)).Gt(object)
+}
@@ -0,0 +1,88 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"hawx.me/code/assert"
+)
+
+func TestPred(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	var (
+		name      = Pred[string]("name")
+		age       = Pred[int]("age")
+		published = Pred[time.Time]("published")
+		author    = Pred[Ref]("author")
+	)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.Nil(t, name.Put(store, "alice", "Alice"))
+	assert.Nil(t, age.Put(store, "alice", 30))
+	assert.Nil(t, name.Put(store, "bob", "Bob"))
+	assert.Nil(t, age.Put(store, "bob", 25))
+	assert.Nil(t, store.Update(func(tx *Tx) error {
+		if err := published.Put(tx, "post", now); err != nil {
+			return err
+		}
+		return author.Put(tx, "post", Ref("alice"))
+	}))
+
+	names, err := name.Get(store, "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Alice"}, names)
+
+	ages, err := age.Get(store, "bob")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{25}, ages)
+
+	store.View(func(tx *Tx) error {
+		times, err := published.Get(tx, "post")
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Time{now}, times)
+
+		authors, err := author.Get(tx, "post")
+		assert.Nil(t, err)
+		assert.Equal(t, []Ref{"alice"}, authors)
+		return nil
+	})
+
+	missing, err := name.Get(store, "post")
+	assert.Nil(t, err)
+	assert.Len(t, missing, 0)
+
+	subjects, err := store.QuerySubjects(age.Gt(27))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice"}, subjects)
+
+	subjects, err = store.QuerySubjects(name.Eq("Bob"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob"}, subjects)
+
+	t.Run("wrong type", func(t *testing.T) {
+		store.Put("carol", "age", "unknown")
+
+		_, err := age.Get(store, "carol")
+		assert.True(t, errors.Is(err, ErrTypeMismatch))
+	})
+
+	t.Run("object", func(t *testing.T) {
+		object, ok := name.Object(Triple{"alice", "name", "Alice"})
+		assert.True(t, ok)
+		assert.Equal(t, "Alice", object)
+
+		_, ok = name.Object(Triple{"alice", "nick", "Al"})
+		assert.False(t, ok)
+
+		_, ok = age.Object(Triple{"alice", "age", "thirty"})
+		assert.False(t, ok)
+	})
+}
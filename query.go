@@ -17,8 +17,22 @@ const (
 	Ne
 	Lt
 	Gt
+	Match
+	Within
+	Near
 )
 
+// ordered returns true if the constraint needs more than equality of values,
+// such as comparing them.
+func (c Constraint) ordered() bool {
+	return c == Lt || c == Gt || c == Within || c == Near
+}
+
+// valued returns true if the constraint's object is a value like those stored.
+func (c Constraint) valued() bool {
+	return c == Eq || c == Ne || c == Lt || c == Gt
+}
+
 type Matcher interface {
 	isMatcher()
 }
@@ -70,6 +84,24 @@ func (q PredicatesMatcher) Gt(object any) PredicatesMatcher {
 	return PredicatesMatcher{predicates: q.predicates, constraint: Gt, object: object}
 }
 
+// Match returns a matcher that matches triples with the predicate and an object
+// containing every term in query. The predicate must use a SearchIndexer.
+func (q PredicatesMatcher) Match(query string) PredicatesMatcher {
+	return PredicatesMatcher{predicates: q.predicates, constraint: Match, object: query}
+}
+
+// Within returns a matcher that matches triples with the predicate and a Point
+// object inside box.
+func (q PredicatesMatcher) Within(box Box) PredicatesMatcher {
+	return PredicatesMatcher{predicates: q.predicates, constraint: Within, object: box}
+}
+
+// Near returns a matcher that matches triples with the predicate and a Point
+// object within radius metres of point.
+func (q PredicatesMatcher) Near(point Point, radius float64) PredicatesMatcher {
+	return PredicatesMatcher{predicates: q.predicates, constraint: Near, object: nearObject{point: point, radius: radius}}
+}
+
 type WithoutMatcher struct {
 	predicates []string
 }
@@ -83,6 +115,8 @@ func (q WithoutMatcher) isSubjectMatcher() {}
 type SortMatcher struct {
 	predicate string
 	desc      bool
+	relevance bool
+	from      *Point
 }
 
 // Sort returns a matcher that causes results to be sorted by the
@@ -95,11 +129,31 @@ func (q SortMatcher) isMatcher()        {}
 func (q SortMatcher) isSubjectMatcher() {}
 
 func (q SortMatcher) Desc() SortMatcher {
-	return SortMatcher{predicate: q.predicate, desc: true}
+	q.desc = true
+	return q
 }
 
 func (q SortMatcher) Asc() SortMatcher {
-	return SortMatcher{predicate: q.predicate}
+	q.desc = false
+	return q
+}
+
+// Relevance returns a matcher that sorts by how well subjects match the Match
+// for the predicate, rather than by its value. The most relevant are first,
+// unless Asc is used.
+func (q SortMatcher) Relevance() SortMatcher {
+	q.relevance = true
+	q.desc = true
+	return q
+}
+
+// Distance returns a matcher that sorts by the distance from point to the
+// closest Point value of the predicate, rather than by its value. The nearest
+// are first, unless Desc is used.
+func (q SortMatcher) Distance(point Point) SortMatcher {
+	q.from = &point
+	q.desc = false
+	return q
 }
 
 type LimitMatcher struct {
@@ -115,149 +169,220 @@ func (q LimitMatcher) isMatcher()        {}
 func (q LimitMatcher) isSubjectMatcher() {}
 
 // QuerySubjects finds subjects that match all of the given matchers.
-func (s *Store) QuerySubjects(matchers ...SubjectMatcher) []string {
+func (s *Store) QuerySubjects(matchers ...SubjectMatcher) ([]string, error) {
+	var val []string
+
+	err := s.view(func(tx *bbolt.Tx) (err error) {
+		val, err = s.querySubjects(tx, matchers...)
+		return err
+	})
+
+	return val, err
+}
+
+func (s *Store) querySubjects(tx *bbolt.Tx, matchers ...SubjectMatcher) ([]string, error) {
 	var val []string
 
 	var (
-		predicates  []string
-		without     []string
-		sortOn      string
-		sortDesc    bool
-		limit       uint
-		constraints = map[string]constraintObject{}
+		terms         []namedConstraint
+		without       []string
+		sortOn        string
+		sortDesc      bool
+		sortRelevance bool
+		sortFrom      *Point
+		limit         uint
+		paths         []pathTerm
+		referencedBy  []ReferencedByMatcher
+		transitive    []TransitiveMatcher
 	)
 	for _, matcher := range matchers {
 		switch v := matcher.(type) {
 		case PredicatesMatcher:
-			predicates = append(predicates, v.predicates...)
-			if v.object != nil {
-				for _, predicate := range v.predicates {
-					constraints[predicate] = constraintObject{
+			for _, predicate := range v.predicates {
+				term := namedConstraint{predicate: predicate}
+				if v.object != nil {
+					term.constraint = &constraintObject{
 						constraint: v.constraint,
 						object:     v.object,
 					}
 				}
+				terms = append(terms, term)
 			}
 		case WithoutMatcher:
 			without = append(without, v.predicates...)
 		case SortMatcher:
 			sortOn = v.predicate
 			sortDesc = v.desc
+			sortRelevance = v.relevance
+			sortFrom = v.from
 		case LimitMatcher:
 			limit = v.count
+		case PathMatcher:
+			path, err := v.term()
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		case ReferencedByMatcher:
+			referencedBy = append(referencedBy, v)
+		case TransitiveMatcher:
+			if v.subject == "" {
+				return nil, fmt.Errorf("no6: transitive matcher for %q needs a subject to reach", v.predicate)
+			}
+			if v.maxDepth < 0 {
+				return nil, fmt.Errorf("no6: transitive matcher for %q has negative max depth %d", v.predicate, v.maxDepth)
+			}
+			transitive = append(transitive, v)
 		}
 	}
 
-	s.db.View(func(tx *bbolt.Tx) error {
-		dataBucket := tx.Bucket(bucketData)
-		if dataBucket == nil {
-			return nil
+	indexers := map[string]Indexer{}
+	for _, term := range terms {
+		if term.constraint != nil {
+			indexer, err := s.prepareConstraint(tx, term.predicate, term.constraint)
+			if err != nil {
+				return nil, err
+			}
+			indexers[term.predicate] = indexer
 		}
+	}
+	for i, path := range paths {
+		if path.constraint != nil {
+			indexer, err := s.prepareConstraint(tx, path.last(), path.constraint)
+			if err != nil {
+				return nil, err
+			}
+			paths[i].indexer = indexer
+		}
+	}
+	if sortOn != "" && !sortRelevance {
+		if _, err := searchable(tx, sortOn, true); err != nil {
+			return nil, err
+		}
+	}
 
-		var subjects []uint64
+	dict := s.readDictionary(tx)
+	if dict.empty() {
+		return nil, nil
+	}
 
-		// start by querying on the predicates we want
-		for qi, predicate := range predicates {
-			predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
-			if predicateBucket == nil {
-				return nil
-			}
+	var subjects []uint64
+	scores := map[string]map[uint64]float64{}
 
-			var thisQuerySubjects []uint64
-
-			predicateBucket.ForEach(func(k, v []byte) error {
-				for i := 0; i < len(v); i += 8 {
-					obj := v[i : i+8]
-
-					var item []byte
-					if constraint, ok := constraints[predicate]; ok {
-						switch constraint.constraint {
-						case Eq:
-							objectUID := dataBucket.Get(s.typer.Format(constraint.object))
-							if !bytes.Equal(objectUID, obj) {
-								continue
-							}
-						case Ne:
-							objectUID := dataBucket.Get(s.typer.Format(constraint.object))
-							if bytes.Equal(objectUID, obj) {
-								continue
-							}
-						case Lt:
-							item = dataBucket.Get(obj)
-							if s.typer.Compare(item, s.typer.Format(constraint.object)) > -1 {
-								continue
-							}
-						case Gt:
-							item = dataBucket.Get(obj)
-							if s.typer.Compare(item, s.typer.Format(constraint.object)) < 1 {
-								continue
-							}
-						}
-					}
+	first := true
+	narrow := func(these []uint64) {
+		if first {
+			subjects = these
+			first = false
+		} else {
+			subjects = intersect(subjects, these)
+		}
+	}
 
-					thisQuerySubjects = append(thisQuerySubjects, keySubject(k))
-				}
+	// start by querying on the predicates we want
+	for _, term := range terms {
+		these, termScores, err := s.subjectsMatching(tx, dict, term.predicate, term.constraint, indexers[term.predicate])
+		if err != nil {
+			return nil, err
+		}
+		if termScores != nil {
+			scores[term.predicate] = termScores
+		}
 
-				return nil
-			})
+		narrow(these)
+	}
 
-			if qi == 0 {
-				subjects = thisQuerySubjects
-			} else {
-				subjects = intersect(subjects, thisQuerySubjects)
-			}
+	for _, path := range paths {
+		these, err := s.pathSubjects(tx, dict, path)
+		if err != nil {
+			return nil, err
 		}
 
-		// now remove anything we shouldn't have
-		for _, predicate := range without {
-			predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
-			if predicateBucket == nil {
-				return nil
-			}
+		narrow(these)
+	}
 
-			predicateBucket.ForEach(func(k, v []byte) error {
-				subjects = remove(subjects, keySubject(k))
-				return nil
-			})
+	for _, ref := range referencedBy {
+		these, err := subjectsReferencing(tx, dict.nodeUID(ref.object), ref.predicates)
+		if err != nil {
+			return nil, err
 		}
 
-		// now sort
-		if sortOn != "" {
-			predicateBucket := tx.Bucket([]byte("predicate-" + sortOn))
-			if predicateBucket == nil {
-				return nil
-			}
+		narrow(these)
+	}
 
-			var sortPredicate [][]byte
-			predicateBucket.ForEach(func(k, v []byte) error {
-				if !slices.Contains(subjects, keySubject(k)) {
-					return nil
-				}
+	for _, q := range transitive {
+		narrow(subjectsReaching(tx, dict, q))
+	}
 
-				for i := 0; i < len(v); i += 8 {
-					obj := v[i : i+8]
-					sortPredicate = append(sortPredicate, dataBucket.Get(obj))
-				}
-				return nil
-			})
+	// now remove anything we shouldn't have
+	for _, predicate := range without {
+		predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+		if predicateBucket == nil {
+			continue
+		}
+
+		subjects = slices.DeleteFunc(subjects, func(subject uint64) bool {
+			return predicateBucket.Get(makeKey(subject, predicate)) != nil
+		})
+	}
 
-			s.sortBy(subjects, sortPredicate, sortDesc)
+	// now sort, subjects without a value for the predicate go last
+	if sortOn != "" && sortRelevance {
+		sortScores, ok := scores[sortOn]
+		if !ok {
+			return nil, fmt.Errorf("no6: sorting by relevance of %q requires matching it", sortOn)
 		}
 
-		// finally trim to the limit
-		if limit != 0 {
-			subjects = subjects[:limit]
+		sortByScore(subjects, sortScores, !sortDesc)
+	} else if sortOn != "" && sortFrom != nil {
+		if predicateBucket := tx.Bucket([]byte("predicate-" + sortOn)); predicateBucket != nil {
+			sortByDistance(dict, predicateBucket, sortOn, subjects, *sortFrom, sortDesc)
 		}
+	} else if sortOn != "" {
+		if orderBucket := tx.Bucket(orderBucketName(sortOn)); orderBucket != nil {
+			subjects = sortByIndex(orderBucket, subjects, sortDesc, limit)
+		} else if predicateBucket := tx.Bucket([]byte("predicate-" + sortOn)); predicateBucket != nil {
+			sortPredicate := make([][]byte, len(subjects))
+			for i, subject := range subjects {
+				postingList := predicateBucket.Get(makeKey(subject, sortOn))
+
+				// when there are many values sort on the first that would be
+				// returned in this order
+				for j := 0; j < len(postingList); j += 8 {
+					item := dict.literal(postingList[j : j+8])
+
+					if sortPredicate[i] == nil {
+						sortPredicate[i] = item
+						continue
+					}
 
-		for _, subj := range subjects {
-			item := dataBucket.Get(writeUID(subj))
-			val = append(val, string(item))
+					c, err := s.typer.Compare(item, sortPredicate[i])
+					if err != nil {
+						return nil, fmt.Errorf("%q: %w", sortOn, err)
+					}
+					if (!sortDesc && c < 0) || (sortDesc && c > 0) {
+						sortPredicate[i] = item
+					}
+				}
+			}
+
+			if err := s.sortBy(subjects, sortPredicate, sortDesc); err != nil {
+				return nil, fmt.Errorf("%q: %w", sortOn, err)
+			}
 		}
+	}
 
-		return nil
-	})
+	// finally trim to the limit
+	if limit != 0 && int(limit) < len(subjects) {
+		subjects = subjects[:limit]
+	}
 
-	return val
+	for _, subj := range subjects {
+		subject, _ := dict.node(writeUID(subj))
+		val = append(val, subject)
+	}
+
+	return val, nil
 }
 
 type namedList struct {
@@ -274,15 +399,125 @@ type namedBucket struct {
 type constraintObject struct {
 	constraint Constraint
 	object     any
+	data       []byte
+}
+
+type namedConstraint struct {
+	predicate  string
+	constraint *constraintObject
+}
+
+// prepareConstraint formats the object of constraint, if needed, and checks
+// that predicate can be queried by it. It returns the indexer used by
+// predicate.
+func (s *Store) prepareConstraint(tx *bbolt.Tx, predicate string, constraint *constraintObject) (Indexer, error) {
+	if constraint.constraint.valued() {
+		data, err := s.typer.Format(constraint.object)
+		if err != nil {
+			return nil, err
+		}
+		constraint.data = data
+	}
+
+	indexer, err := searchable(tx, predicate, constraint.constraint.ordered())
+	if err != nil {
+		return nil, err
+	}
+	if constraint.constraint == Match {
+		if err := matchable(indexer, predicate); err != nil {
+			return nil, err
+		}
+	}
+
+	return indexer, nil
+}
+
+// subjectsMatching returns the subjects with a value for predicate that meets
+// constraint, or with any value if constraint is nil. When the constraint is a
+// Match the score of each subject is also returned.
+func (s *Store) subjectsMatching(tx *bbolt.Tx, dict *dictionary, predicate string, constraint *constraintObject, indexer Indexer) ([]uint64, map[uint64]float64, error) {
+	predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+	if predicateBucket == nil {
+		return nil, nil, nil
+	}
+	orderBucket := tx.Bucket(orderBucketName(predicate))
+
+	switch {
+	case constraint != nil && constraint.constraint == Eq:
+		objectUID := dict.objectUID(indexer, constraint.data)
+		return subjectsWith(tx, predicate, objectUID), nil, nil
+
+	case constraint != nil && constraint.constraint == Ne:
+		objectUID := dict.objectUID(indexer, constraint.data)
+		return subjectsWithout(tx, predicate, objectUID), nil, nil
+
+	case constraint != nil && constraint.constraint == Match:
+		index, err := openSearchIndex(tx, predicate)
+		if err != nil || index == nil {
+			return nil, nil, err
+		}
+
+		subjects, scores := index.match(constraint.object.(string))
+		return subjects, scores, nil
+
+	case constraint != nil && constraint.constraint == Within:
+		subjects, _ := subjectsWithin(tx, predicate, constraint.object.(Box))
+		return subjects, nil, nil
+
+	case constraint != nil && constraint.constraint == Near:
+		near := constraint.object.(nearObject)
+		return subjectsNear(tx, predicate, near.point, near.radius), nil, nil
+
+	case constraint != nil && orderBucket != nil:
+		return subjectsInRange(orderBucket, constraint.constraint, constraint.data), nil, nil
+	}
+
+	var subjects []uint64
+	if err := predicateBucket.ForEach(func(k, v []byte) error {
+		for i := 0; i < len(v); i += 8 {
+			obj := v[i : i+8]
+
+			if constraint != nil {
+				c, err := s.typer.Compare(dict.literal(obj), constraint.data)
+				if err != nil {
+					return err
+				}
+				if (constraint.constraint == Lt && c > -1) || (constraint.constraint == Gt && c < 1) {
+					continue
+				}
+			}
+
+			subjects = append(subjects, keySubject(k))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("%q: %w", predicate, err)
+	}
+
+	return sortUnique(subjects), nil, nil
 }
 
 // Query returns the results matching the given matchers.
-func (s *Store) Query(matchers ...Matcher) []Triple {
+func (s *Store) Query(matchers ...Matcher) ([]Triple, error) {
+	var val []Triple
+
+	err := s.view(func(tx *bbolt.Tx) (err error) {
+		val, err = s.query(tx, matchers...)
+		return err
+	})
+
+	return val, err
+}
+
+func (s *Store) query(tx *bbolt.Tx, matchers ...Matcher) ([]Triple, error) {
 	var val []Triple
 
 	var predicates []string
 	var subjects []string
+	var langs *LangMatcher
 	constraints := map[string]constraintObject{}
+	paths := map[string][]pathTerm{}
 	for _, matcher := range matchers {
 		switch v := matcher.(type) {
 		case PredicatesMatcher:
@@ -297,117 +532,208 @@ func (s *Store) Query(matchers ...Matcher) []Triple {
 			}
 		case SubjectsMatcher:
 			subjects = append(subjects, v.subjects...)
+		case LangMatcher:
+			langs = &v
+		case PathMatcher:
+			path, err := v.term()
+			if err != nil {
+				return nil, err
+			}
+
+			first := path.path[0]
+			predicates = append(predicates, first)
+			if len(path.path) == 1 {
+				if path.constraint != nil {
+					constraints[first] = *path.constraint
+				}
+				continue
+			}
+
+			// the objects of the first predicate must lead along the rest
+			path.path = path.path[1:]
+			paths[first] = append(paths[first], path)
 		}
 	}
 
-	s.db.View(func(tx *bbolt.Tx) error {
-		dataBucket := tx.Bucket(bucketData)
-		if dataBucket == nil {
-			return nil
+	indexers := map[string]Indexer{}
+	for predicate, constraint := range constraints {
+		indexer, err := s.prepareConstraint(tx, predicate, &constraint)
+		if err != nil {
+			return nil, err
+		}
+		constraints[predicate] = constraint
+		indexers[predicate] = indexer
+	}
+	for _, predicatePaths := range paths {
+		for i, path := range predicatePaths {
+			if path.constraint != nil {
+				indexer, err := s.prepareConstraint(tx, path.last(), path.constraint)
+				if err != nil {
+					return nil, err
+				}
+				predicatePaths[i].indexer = indexer
+			}
 		}
+	}
 
-		// step 1. figure out which buckets/predicates are needed.
-		// step 2. figure out which posting lists/subject-predicates are needed.
-		// step 3. figure out what objects to match each predicate to
+	dict := s.readDictionary(tx)
+	if dict.empty() {
+		return nil, nil
+	}
 
-		var predicateBuckets []namedBucket
-		if len(predicates) > 0 {
-			for _, p := range predicates {
-				b := tx.Bucket([]byte("predicate-" + p))
-				if b == nil {
-					continue
-				}
+	// step 1. figure out which buckets/predicates are needed.
+	// step 2. figure out which posting lists/subject-predicates are needed.
+	// step 3. figure out what objects to match each predicate to
+
+	var predicateBuckets []namedBucket
+	if len(predicates) > 0 {
+		for _, p := range predicates {
+			b := tx.Bucket([]byte("predicate-" + p))
+			if b == nil {
+				continue
+			}
+			predicateBuckets = append(predicateBuckets, namedBucket{predicate: p, bucket: b})
+		}
+	} else {
+		tx.Bucket(bucketPredicates).ForEach(func(k []byte, _ []byte) error {
+			p := string(k)
+			if b := tx.Bucket([]byte("predicate-" + p)); b != nil {
 				predicateBuckets = append(predicateBuckets, namedBucket{predicate: p, bucket: b})
 			}
-		} else {
-			tx.Bucket(bucketPredicates).ForEach(func(k []byte, _ []byte) error {
-				p := string(k)
-				if b := tx.Bucket([]byte("predicate-" + p)); b != nil {
-					predicateBuckets = append(predicateBuckets, namedBucket{predicate: p, bucket: b})
-				}
 
-				return nil
-			})
-		}
+			return nil
+		})
+	}
+
+	var postingLists []namedList
+	if len(subjects) > 0 {
+		for _, subject := range subjects {
+			subjectUID := dict.nodeUID(subject)
+			if subjectUID == nil {
+				return nil, nil
+			}
 
-		var postingLists []namedList
-		if len(subjects) > 0 {
-			for _, subject := range subjects {
-				subjectUID := dataBucket.Get([]byte(subject))
-				if subjectUID == nil {
-					return nil
+			for _, nb := range predicateBuckets {
+				key := makeKey(readUID(subjectUID), nb.predicate)
+
+				postingList := nb.bucket.Get(key)
+				if postingList == nil {
+					continue
 				}
 
-				for _, nb := range predicateBuckets {
-					key := makeKey(readUID(subjectUID), nb.predicate)
+				postingLists = append(postingLists, namedList{subject: subject, predicate: nb.predicate, list: postingList})
+			}
+		}
+	} else {
+		for _, nb := range predicateBuckets {
+			// when looking for a value only the subjects that have it are needed
+			if constraint, ok := constraints[nb.predicate]; ok && constraint.constraint == Eq {
+				objectUID := dict.objectUID(indexers[nb.predicate], constraint.data)
 
-					postingList := nb.bucket.Get(key)
-					if postingList == nil {
-						continue
-					}
+				for _, subjectUID := range subjectsWith(tx, nb.predicate, objectUID) {
+					subject, _ := dict.node(writeUID(subjectUID))
+					postingList := nb.bucket.Get(makeKey(subjectUID, nb.predicate))
 
 					postingLists = append(postingLists, namedList{subject: subject, predicate: nb.predicate, list: postingList})
 				}
+				continue
 			}
-		} else {
-			for _, nb := range predicateBuckets {
-				nb.bucket.ForEach(func(k, v []byte) error {
-					subjectVal := dataBucket.Get([]byte(k[:8]))
 
-					postingLists = append(postingLists, namedList{subject: string(subjectVal), predicate: nb.predicate, list: v})
-					return nil
-				})
-			}
+			nb.bucket.ForEach(func(k, v []byte) error {
+				subject, _ := dict.node(k[:8])
+
+				postingLists = append(postingLists, namedList{subject: subject, predicate: nb.predicate, list: v})
+				return nil
+			})
 		}
+	}
 
-		s.logger.Debug("checking posting lists", slog.Int("count", len(postingLists)))
-		if len(postingLists) == 0 {
-			return nil
+	s.logger.Debug("checking posting lists", slog.Int("count", len(postingLists)))
+	if len(postingLists) == 0 {
+		return nil, nil
+	}
+
+	linked := map[string][][]uint64{}
+	for predicate, predicatePaths := range paths {
+		for _, path := range predicatePaths {
+			these, err := s.pathSubjects(tx, dict, path)
+			if err != nil {
+				return nil, err
+			}
+			linked[predicate] = append(linked[predicate], these)
 		}
+	}
 
-		for _, postingList := range postingLists {
-			for i := 0; i < len(postingList.list); i += 8 {
-				obj := postingList.list[i : i+8]
-
-				var data []byte
-				if constraint, ok := constraints[postingList.predicate]; ok {
-					switch constraint.constraint {
-					case Eq:
-						objectUID := dataBucket.Get(s.typer.Format(constraint.object))
-						if !bytes.Equal(objectUID, obj) {
-							continue
-						}
-					case Ne:
-						objectUID := dataBucket.Get(s.typer.Format(constraint.object))
-						if bytes.Equal(objectUID, obj) {
-							continue
-						}
-					case Lt:
-						data = dataBucket.Get(obj)
-						if s.typer.Compare(data, s.typer.Format(constraint.object)) > -1 {
-							continue
-						}
-					case Gt:
-						data = dataBucket.Get(obj)
-						if s.typer.Compare(data, s.typer.Format(constraint.object)) < 1 {
-							continue
-						}
-					}
-				}
+	for _, postingList := range postingLists {
+		var triples []Triple
 
-				if data == nil {
-					data = dataBucket.Get(obj)
+		for i := 0; i < len(postingList.list); i += 8 {
+			obj := postingList.list[i : i+8]
+			if !linkedToAll(linked[postingList.predicate], readUID(obj)) {
+				continue
+			}
+
+			var data []byte
+			if constraint, ok := constraints[postingList.predicate]; ok {
+				switch constraint.constraint {
+				case Eq:
+					objectUID := dict.objectUID(indexers[postingList.predicate], constraint.data)
+					if !bytes.Equal(objectUID, obj) {
+						continue
+					}
+				case Ne:
+					objectUID := dict.objectUID(indexers[postingList.predicate], constraint.data)
+					if bytes.Equal(objectUID, obj) {
+						continue
+					}
+				case Lt, Gt:
+					data = dict.literal(obj)
+					c, err := s.typer.Compare(data, constraint.data)
+					if err != nil {
+						return nil, fmt.Errorf("%q: %w", postingList.predicate, err)
+					}
+					if (constraint.constraint == Lt && c > -1) || (constraint.constraint == Gt && c < 1) {
+						continue
+					}
+				case Match:
+					data = dict.literal(obj)
+					if !indexers[postingList.predicate].(SearchIndexer).matches(data, constraint.object.(string)) {
+						continue
+					}
+				case Within:
+					data = dict.literal(obj)
+					point, ok := pointOf(data)
+					if !ok || !constraint.object.(Box).contains(point) {
+						continue
+					}
+				case Near:
+					data = dict.literal(obj)
+					point, ok := pointOf(data)
+					near := constraint.object.(nearObject)
+					if !ok || near.point.Distance(point) > near.radius {
+						continue
+					}
 				}
-				_, item := s.typer.Read(data)
+			}
 
-				val = append(val, Triple{Subject: postingList.subject, Predicate: postingList.predicate, Object: item})
+			if data == nil {
+				data = dict.literal(obj)
+			}
+			_, item, err := s.typer.Read(data)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", postingList.predicate, err)
 			}
+
+			triples = append(triples, Triple{Subject: postingList.subject, Predicate: postingList.predicate, Object: item})
 		}
 
-		return nil
-	})
+		if langs != nil {
+			triples = chooseLang(triples, langs.tags)
+		}
+		val = append(val, triples...)
+	}
 
-	return val
+	return val, nil
 }
 
 func intersect(a, b []uint64) []uint64 {
@@ -429,6 +755,12 @@ func intersect(a, b []uint64) []uint64 {
 	return result
 }
 
+// sortUnique sorts a and removes any repeated values.
+func sortUnique(a []uint64) []uint64 {
+	slices.Sort(a)
+	return slices.Compact(a)
+}
+
 func remove(a []uint64, b uint64) []uint64 {
 	if a == nil {
 		return nil
@@ -445,15 +777,17 @@ func remove(a []uint64, b uint64) []uint64 {
 	return slices.Delete(a, idx, idx+1)
 }
 
-// sortBy will sort as to follow the ordering of bs.
-func (s *Store) sortBy(as []uint64, bs [][]byte, desc bool) {
+// sortBy will sort as to follow the ordering of bs. Any nil values in bs are
+// placed at the end, whichever the direction. If any values in bs cannot be
+// compared the first error is returned, and as is left in an unspecified order.
+func (s *Store) sortBy(as []uint64, bs [][]byte, desc bool) error {
 	type paired struct {
 		a uint64
 		b []byte
 	}
 
 	if len(as) != len(bs) {
-		panic(fmt.Sprintf("%d must equal %d", len(as), len(bs)))
+		return fmt.Errorf("no6: sorting %d subjects by %d values", len(as), len(bs))
 	}
 
 	pairs := make([]paired, len(as))
@@ -461,17 +795,31 @@ func (s *Store) sortBy(as []uint64, bs [][]byte, desc bool) {
 		pairs[i] = paired{a: as[i], b: bs[i]}
 	}
 
-	if desc {
-		slices.SortFunc(pairs, func(i, j paired) int {
-			return s.typer.Compare(j.b, i.b)
-		})
-	} else {
-		slices.SortFunc(pairs, func(i, j paired) int {
-			return s.typer.Compare(i.b, j.b)
-		})
-	}
+	var err error
+	slices.SortStableFunc(pairs, func(i, j paired) int {
+		switch {
+		case i.b == nil && j.b == nil:
+			return 0
+		case i.b == nil:
+			return 1
+		case j.b == nil:
+			return -1
+		}
+
+		if desc {
+			i, j = j, i
+		}
+
+		c, cerr := s.typer.Compare(i.b, j.b)
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+		return c
+	})
 
 	for i := range as {
 		as[i] = pairs[i].a
 	}
+
+	return err
 }
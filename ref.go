@@ -0,0 +1,21 @@
+package no6
+
+// A Ref is an object that refers to another subject, rather than being a value
+// itself. So
+//
+//	Triple{"post", "author", Ref("alice")}
+//
+// links the subjects "post" and "alice", where the string "alice" would only be
+// a name. Refs are stored as the UID of the subject they refer to, which does
+// not need to have any triples of its own.
+type Ref string
+
+// refOf returns the subject that the formatted object data refers to, and false
+// if it is not a Ref.
+func refOf(data []byte) (string, bool) {
+	if len(data) == 0 || Type(data[0]) != TypeRef {
+		return "", false
+	}
+
+	return string(data[1:]), true
+}
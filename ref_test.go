@@ -0,0 +1,86 @@
+package no6
+
+import (
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestTyperRef(t *testing.T) {
+	typer := &Typer{}
+
+	typ, read := mustRead(typer, mustFormat(typer, Ref("alice")))
+	assert.Equal(t, TypeRef, typ)
+	assert.Equal(t, Ref("alice"), read)
+}
+
+func TestRef(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"alice", "name", "Alice"},
+		Triple{"post", "author", Ref("alice")},
+		Triple{"post", "author", "alice"},
+		Triple{"post", "mentions", Ref("bob")},
+	))
+
+	t.Run("query", func(t *testing.T) {
+		triples, err := store.Query(Subjects("post"), Predicates("author"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"post", "author", Ref("alice")},
+			{"post", "author", "alice"},
+		}, triples)
+
+		triples, err = store.Query(Predicates("author").Eq(Ref("alice")))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"post", "author", Ref("alice")}}, triples)
+	})
+
+	t.Run("stored as node", func(t *testing.T) {
+		store.db.View(func(tx *bbolt.Tx) error {
+			dict := store.readDictionary(tx)
+			postingList := tx.Bucket([]byte("predicate-author")).Get(makeKey(readUID(dict.nodeUID("post")), "author"))
+			assert.Equal(t, dict.nodeUID("alice"), postingList[:8])
+			return nil
+		})
+	})
+
+	t.Run("subjects", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("mentions").Eq(Ref("bob")))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"post"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("mentions").Eq("bob"))
+		assert.Nil(t, err)
+		assert.Len(t, subjects, 0)
+	})
+
+	t.Run("reindex", func(t *testing.T) {
+		assert.Nil(t, store.SetIndexer("author", HashIndexer{}))
+
+		subjects, err := store.QuerySubjects(Predicates("author").Eq(Ref("alice")))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"post"}, subjects)
+	})
+
+	t.Run("vacuum", func(t *testing.T) {
+		assert.Nil(t, store.DeleteSubject("alice"))
+
+		_, err := store.Vacuum(VacuumOptions{})
+		assert.Nil(t, err)
+
+		assert.Nil(t, store.DeleteTriple("post", "author", Ref("alice")))
+
+		triples, err := store.Query(Subjects("post"), Predicates("author"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"post", "author", "alice"}}, triples)
+	})
+}
@@ -0,0 +1,189 @@
+package no6
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// The reverse-* bucket for a predicate contains (ID(object), subjects) pairs,
+// where subjects is a posting list of every subject that has the object as a
+// value for the predicate. It is kept in step with the predicate-* bucket so
+// that finding subjects by value does not need to look at every posting list.
+//
+// It is kept for every predicate, though for those using the NilIndexer only
+// Ref objects are recorded.
+
+func reverseBucketName(predicate string) []byte {
+	return []byte("reverse-" + predicate)
+}
+
+// addReverse records that subjectUID has objectUID for predicate.
+func addReverse(tx *bbolt.Tx, predicate string, objectUID, subjectUID []byte) error {
+	reverseBucket, err := tx.CreateBucketIfNotExists(reverseBucketName(predicate))
+	if err != nil {
+		return err
+	}
+
+	subjectList := reverseBucket.Get(objectUID)
+	updatedList := appendValue(subjectList, readUID(subjectUID))
+	if len(updatedList) == len(subjectList) {
+		return nil
+	}
+
+	return reverseBucket.Put(objectUID, updatedList)
+}
+
+// removeReverse records that subjectUID no longer has any of the objects in
+// objectList for predicate.
+func removeReverse(tx *bbolt.Tx, predicate string, objectList, subjectUID []byte) error {
+	reverseBucket := tx.Bucket(reverseBucketName(predicate))
+	if reverseBucket == nil {
+		return nil
+	}
+
+	for i := 0; i < len(objectList); i += 8 {
+		objectUID := objectList[i : i+8]
+
+		subjectList := reverseBucket.Get(objectUID)
+		updatedList := removeValue(subjectList, readUID(subjectUID))
+		if len(updatedList) == len(subjectList) {
+			continue
+		}
+
+		if len(updatedList) == 0 {
+			if err := reverseBucket.Delete(objectUID); err != nil {
+				return err
+			}
+		} else if err := reverseBucket.Put(objectUID, updatedList); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subjectsWith returns the subjects that have objectUID for predicate.
+func subjectsWith(tx *bbolt.Tx, predicate string, objectUID []byte) []uint64 {
+	reverseBucket := tx.Bucket(reverseBucketName(predicate))
+	if reverseBucket == nil || objectUID == nil {
+		return nil
+	}
+
+	return readList(reverseBucket.Get(objectUID))
+}
+
+// subjectsWithout returns the subjects that have a value other than objectUID
+// for predicate.
+func subjectsWithout(tx *bbolt.Tx, predicate string, objectUID []byte) []uint64 {
+	reverseBucket := tx.Bucket(reverseBucketName(predicate))
+	if reverseBucket == nil {
+		return nil
+	}
+
+	var subjects []uint64
+	reverseBucket.ForEach(func(k, v []byte) error {
+		if !bytes.Equal(k, objectUID) {
+			subjects = append(subjects, readList(v)...)
+		}
+		return nil
+	})
+
+	return sortUnique(subjects)
+}
+
+// Incoming returns the triples with a Ref to object, for any of predicates or
+// for every predicate if none are given. So
+//
+//	store.Incoming("dave", "knows")
+//
+// finds who knows dave.
+func (s *Store) Incoming(object string, predicates ...string) ([]Triple, error) {
+	var val []Triple
+
+	err := s.view(func(tx *bbolt.Tx) (err error) {
+		val, err = s.incoming(tx, object, predicates)
+		return err
+	})
+
+	return val, err
+}
+
+func (s *Store) incoming(tx *bbolt.Tx, object string, predicates []string) ([]Triple, error) {
+	dict := s.readDictionary(tx)
+
+	objectUID := dict.nodeUID(object)
+	if objectUID == nil {
+		return nil, nil
+	}
+
+	predicates, err := predicatesOrAll(tx, predicates)
+	if err != nil {
+		return nil, err
+	}
+
+	var triples []Triple
+	for _, predicate := range predicates {
+		for _, subjectUID := range subjectsWith(tx, predicate, objectUID) {
+			subject, ok := dict.node(writeUID(subjectUID))
+			if !ok {
+				return nil, fmt.Errorf("no6: no subject for uid %d in reverse index of %q", subjectUID, predicate)
+			}
+
+			triples = append(triples, Triple{Subject: subject, Predicate: predicate, Object: Ref(object)})
+		}
+	}
+
+	return triples, nil
+}
+
+// subjectsReferencing returns the subjects with a Ref to objectUID for any of
+// predicates, or for every predicate if none are given.
+func subjectsReferencing(tx *bbolt.Tx, objectUID []byte, predicates []string) ([]uint64, error) {
+	predicates, err := predicatesOrAll(tx, predicates)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []uint64
+	for _, predicate := range predicates {
+		subjects = append(subjects, subjectsWith(tx, predicate, objectUID)...)
+	}
+
+	return sortUnique(subjects), nil
+}
+
+// predicatesOrAll returns predicates, or if none are given every predicate in
+// the store.
+func predicatesOrAll(tx *bbolt.Tx, predicates []string) ([]string, error) {
+	if len(predicates) > 0 {
+		return predicates, nil
+	}
+
+	predicatesBucket := tx.Bucket(bucketPredicates)
+	if predicatesBucket == nil {
+		return nil, nil
+	}
+
+	err := predicatesBucket.ForEach(func(k, _ []byte) error {
+		predicates = append(predicates, string(k))
+		return nil
+	})
+
+	return predicates, err
+}
+
+type ReferencedByMatcher struct {
+	object     string
+	predicates []string
+}
+
+// ReferencedBy returns a matcher that matches the subjects by which object is
+// referenced, with a Ref for any of predicates or for any predicate if none are
+// given. It matches the same subjects as Incoming returns triples for.
+func ReferencedBy(object string, predicates ...string) ReferencedByMatcher {
+	return ReferencedByMatcher{object: object, predicates: predicates}
+}
+
+func (q ReferencedByMatcher) isSubjectMatcher() {}
@@ -0,0 +1,147 @@
+package no6
+
+import (
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestReverseIndex(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	reverse := func(object any) []string {
+		var subjects []string
+		store.db.View(func(tx *bbolt.Tx) error {
+			dict := store.readDictionary(tx)
+			for _, uid := range subjectsWith(tx, "tag", dict.literalUID(mustFormat(store.typer, object))) {
+				subject, _ := dict.node(writeUID(uid))
+				subjects = append(subjects, subject)
+			}
+			return nil
+		})
+		return subjects
+	}
+
+	store.PutTriples(
+		Triple{"a", "tag", "go"},
+		Triple{"a", "tag", "rust"},
+		Triple{"b", "tag", "go"},
+		Triple{"c", "tag", "go"},
+		Triple{"c", "tag", "rust"},
+		Triple{"d", "tag", "rust"},
+	)
+	assert.Equal(t, []string{"a", "b", "c"}, reverse("go"))
+	assert.Equal(t, []string{"a", "c", "d"}, reverse("rust"))
+
+	store.DeleteTriple("a", "tag", "go")
+	assert.Equal(t, []string{"b", "c"}, reverse("go"))
+	assert.Equal(t, []string{"a", "c", "d"}, reverse("rust"))
+
+	store.Delete("c", "tag")
+	assert.Equal(t, []string{"b"}, reverse("go"))
+	assert.Equal(t, []string{"a", "d"}, reverse("rust"))
+
+	store.DeleteSubject("b")
+	assert.Equal(t, []string(nil), reverse("go"))
+	assert.Equal(t, []string{"a", "d"}, reverse("rust"))
+}
+
+func TestIncoming(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"alice", "knows", Ref("dave")},
+		Triple{"bob", "knows", Ref("dave")},
+		Triple{"bob", "knows", Ref("alice")},
+		Triple{"carol", "follows", Ref("dave")},
+		Triple{"carol", "name", "dave"},
+		Triple{"post", "author", Ref("dave")},
+		Triple{"post", "type", "h-entry"},
+	))
+
+	t.Run("Incoming", func(t *testing.T) {
+		triples, err := store.Incoming("dave", "knows")
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"alice", "knows", Ref("dave")},
+			{"bob", "knows", Ref("dave")},
+		}, triples)
+
+		triples, err = store.Incoming("dave")
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"post", "author", Ref("dave")},
+			{"carol", "follows", Ref("dave")},
+			{"alice", "knows", Ref("dave")},
+			{"bob", "knows", Ref("dave")},
+		}, triples)
+
+		triples, err = store.Incoming("nobody")
+		assert.Nil(t, err)
+		assert.Len(t, triples, 0)
+	})
+
+	t.Run("ReferencedBy", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(ReferencedBy("dave"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"alice", "bob", "carol", "post"}, subjects)
+
+		subjects, err = store.QuerySubjects(ReferencedBy("dave", "author"), Predicates("type").Eq("h-entry"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"post"}, subjects)
+
+		subjects, err = store.QuerySubjects(ReferencedBy("dave", "knows"), ReferencedBy("alice"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bob"}, subjects)
+	})
+
+	t.Run("unindexed", func(t *testing.T) {
+		assert.Nil(t, store.SetIndexer("knows", NilIndexer{}))
+		assert.Nil(t, store.Put("erin", "knows", Ref("dave")))
+
+		subjects, err := store.QuerySubjects(ReferencedBy("dave", "knows"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"alice", "bob", "erin"}, subjects)
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		assert.Nil(t, store.DeleteTriple("alice", "knows", Ref("dave")))
+		assert.Nil(t, store.DeleteSubject("post"))
+
+		triples, err := store.Incoming("dave")
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"carol", "follows", Ref("dave")},
+			{"bob", "knows", Ref("dave")},
+			{"erin", "knows", Ref("dave")},
+		}, triples)
+	})
+
+	t.Run("missing subject", func(t *testing.T) {
+		store.db.Update(func(tx *bbolt.Tx) error {
+			dict, _ := store.writeDictionary(tx)
+			return dict.remove(dict.nodeUID("erin"))
+		})
+
+		_, err := store.Incoming("dave", "knows")
+		assert.NotNil(t, err)
+
+		var txErr error
+		store.View(func(tx *Tx) error {
+			_, txErr = tx.Incoming("dave")
+			return nil
+		})
+		assert.NotNil(t, txErr)
+	})
+}
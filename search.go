@@ -0,0 +1,371 @@
+package no6
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.etcd.io/bbolt"
+)
+
+// The terms-* and lengths-* buckets for a predicate, if they exist, make up an
+// inverted index of the words in its string values. The terms-* bucket contains
+// (term+0+ID(subject), count) pairs, where count is the number of times the
+// term appears across the subject's values, so that a cursor can find every
+// subject with a term. The lengths-* bucket contains (ID(subject), count) pairs
+// of the number of terms each subject has, which is needed to rank results.
+//
+// They are only kept for predicates using the SearchIndexer.
+
+func termsBucketName(predicate string) []byte {
+	return []byte("terms-" + predicate)
+}
+
+func lengthsBucketName(predicate string) []byte {
+	return []byte("lengths-" + predicate)
+}
+
+// A SearchIndexer will store objects by a hash of their value, like the
+// HashIndexer, so that long pieces of text are not used as keys. It also splits
+// string values into terms so that they can be found with Match. Terms are
+// lower-cased letters and numbers, with common accents removed and common
+// English words skipped.
+//
+// Unlike the HashIndexer objects can still be compared and sorted, though
+// without an ordered index every value must be compared.
+type SearchIndexer struct {
+	// Stem reduces terms to a simpler form, for English words, so that "run",
+	// "runs" and "running" all match each other.
+	Stem bool
+}
+
+func (i SearchIndexer) Index(data []byte) []byte {
+	return HashIndexer{}.Index(data)
+}
+
+func (i SearchIndexer) name() string {
+	if i.Stem {
+		return "search-stem"
+	}
+
+	return "search"
+}
+
+// terms splits text into the terms that are indexed.
+func (i SearchIndexer) terms(text string) []string {
+	fields := strings.FieldsFunc(strings.Map(foldRune, text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if _, ok := stopWords[field]; ok {
+			continue
+		}
+		if i.Stem {
+			field = stem(field)
+		}
+
+		terms = append(terms, field)
+	}
+
+	return terms
+}
+
+// matches returns true if the formatted object data contains every term in
+// query.
+func (i SearchIndexer) matches(data []byte, query string) bool {
+	text, ok := textOf(data)
+	if !ok {
+		return false
+	}
+
+	want := i.terms(query)
+	if len(want) == 0 {
+		return false
+	}
+
+	have := map[string]struct{}{}
+	for _, term := range i.terms(text) {
+		have[term] = struct{}{}
+	}
+
+	for _, term := range want {
+		if _, ok := have[term]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+var stopWords = map[string]struct{}{}
+
+func init() {
+	for _, word := range strings.Fields(`a an and are as at be but by for from has
+		have he her his i if in into is it its of on or she so such that the their
+		then there these they this to was we were will with you your`) {
+		stopWords[word] = struct{}{}
+	}
+}
+
+// foldRune lower-cases r, and removes the accent from common accented latin
+// letters.
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+
+	switch r {
+	case 'à', 'á', 'â', 'ã', 'ä', 'å':
+		return 'a'
+	case 'ç':
+		return 'c'
+	case 'è', 'é', 'ê', 'ë':
+		return 'e'
+	case 'ì', 'í', 'î', 'ï':
+		return 'i'
+	case 'ñ':
+		return 'n'
+	case 'ò', 'ó', 'ô', 'õ', 'ö', 'ø':
+		return 'o'
+	case 'ù', 'ú', 'û', 'ü':
+		return 'u'
+	case 'ý', 'ÿ':
+		return 'y'
+	}
+
+	return r
+}
+
+// stem removes common English suffixes from term. It is much simpler than a
+// real stemmer, but catches plurals and the usual verb forms.
+func stem(term string) string {
+	switch {
+	case strings.HasSuffix(term, "sses"):
+		term = term[:len(term)-2]
+	case strings.HasSuffix(term, "ies") && len(term) > 4:
+		term = term[:len(term)-3] + "y"
+	case strings.HasSuffix(term, "s") && len(term) > 3 &&
+		!strings.HasSuffix(term, "ss") && !strings.HasSuffix(term, "us") && !strings.HasSuffix(term, "is"):
+		term = term[:len(term)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed", "ly"} {
+		if base, ok := strings.CutSuffix(term, suffix); ok && len(base) > 2 && strings.ContainsAny(base, "aeiouy") {
+			term = base
+
+			// running -> runn -> run
+			if n := len(term); term[n-1] == term[n-2] && !strings.ContainsRune("aeiouylsz", rune(term[n-1])) {
+				term = term[:n-1]
+			}
+			break
+		}
+	}
+
+	return term
+}
+
+// A searchIndex gives access to the inverted index of a predicate within a
+// transaction.
+type searchIndex struct {
+	indexer SearchIndexer
+	terms   *bbolt.Bucket
+	lengths *bbolt.Bucket
+}
+
+// openSearchIndex returns the inverted index for predicate, or nil if it is not
+// using a SearchIndexer.
+func openSearchIndex(tx *bbolt.Tx, predicate string) (*searchIndex, error) {
+	indexer, err := indexerFor(tx, predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	searchIndexer, ok := indexer.(SearchIndexer)
+	if !ok {
+		return nil, nil
+	}
+
+	termsBucket := tx.Bucket(termsBucketName(predicate))
+	lengthsBucket := tx.Bucket(lengthsBucketName(predicate))
+	if termsBucket == nil || lengthsBucket == nil {
+		return nil, nil
+	}
+
+	return &searchIndex{indexer: searchIndexer, terms: termsBucket, lengths: lengthsBucket}, nil
+}
+
+// addSearch records the terms in the formatted object data against subjectUID
+// for predicate, if the predicate is being searched.
+func addSearch(tx *bbolt.Tx, predicate string, data, subjectUID []byte) error {
+	index, err := openSearchIndex(tx, predicate)
+	if err != nil || index == nil {
+		return err
+	}
+
+	return index.update(data, subjectUID, 1)
+}
+
+// removeSearch removes the terms of the objects in objectList from subjectUID
+// for predicate, if the predicate is being searched.
+func removeSearch(tx *bbolt.Tx, dict *dictionary, predicate string, objectList, subjectUID []byte) error {
+	index, err := openSearchIndex(tx, predicate)
+	if err != nil || index == nil {
+		return err
+	}
+
+	for i := 0; i < len(objectList); i += 8 {
+		if err := index.update(dict.literal(objectList[i:i+8]), subjectUID, -1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// update adds (or with a negative delta, removes) the terms in the formatted
+// object data to the counts for subjectUID.
+func (index *searchIndex) update(data, subjectUID []byte, delta int) error {
+	text, ok := textOf(data)
+	if !ok {
+		return nil
+	}
+
+	terms := index.indexer.terms(text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, term := range terms {
+		counts[term]++
+	}
+
+	for term, count := range counts {
+		if err := putCount(index.terms, termKey(term, subjectUID), delta*count); err != nil {
+			return err
+		}
+	}
+
+	return putCount(index.lengths, subjectUID, delta*len(terms))
+}
+
+// putCount adds delta to the count stored at key, deleting it if the count
+// reaches zero.
+func putCount(bucket *bbolt.Bucket, key []byte, delta int) error {
+	count := delta
+	if v := bucket.Get(key); v != nil {
+		count += int(readUID(v))
+	}
+
+	if count <= 0 {
+		return bucket.Delete(key)
+	}
+
+	return bucket.Put(key, writeUID(uint64(count)))
+}
+
+func termKey(term string, subjectUID []byte) []byte {
+	key := make([]byte, 0, len(term)+9)
+	key = append(key, term...)
+	key = append(key, 0)
+	return append(key, subjectUID...)
+}
+
+// BM25 parameters, k1 controls how quickly repeating a term stops adding to the
+// score, and b how much longer values are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// match returns the subjects that have every term of query, with their BM25
+// score.
+func (index *searchIndex) match(query string) ([]uint64, map[uint64]float64) {
+	var terms []string
+	seen := map[string]struct{}{}
+	for _, term := range index.indexer.terms(query) {
+		if _, ok := seen[term]; !ok {
+			seen[term] = struct{}{}
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var subjectCount, termCount float64
+	index.lengths.ForEach(func(_, v []byte) error {
+		subjectCount++
+		termCount += float64(readUID(v))
+		return nil
+	})
+	if subjectCount == 0 {
+		return nil, nil
+	}
+	averageLength := termCount / subjectCount
+
+	var subjects []uint64
+	scores := map[uint64]float64{}
+
+	for ti, term := range terms {
+		prefix := termKey(term, nil)
+
+		var termSubjects []uint64
+		frequencies := map[uint64]float64{}
+
+		c := index.terms.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			subject := readUID(k[len(prefix):])
+			termSubjects = append(termSubjects, subject)
+			frequencies[subject] = float64(readUID(v))
+		}
+		termSubjects = sortUnique(termSubjects)
+
+		if ti == 0 {
+			subjects = termSubjects
+		} else {
+			subjects = intersect(subjects, termSubjects)
+		}
+
+		matching := float64(len(termSubjects))
+		idf := math.Log(1 + (subjectCount-matching+0.5)/(matching+0.5))
+
+		for subject, tf := range frequencies {
+			length := float64(readUID(index.lengths.Get(writeUID(subject))))
+			scores[subject] += idf * tf * (bm25K1 + 1) /
+				(tf + bm25K1*(1-bm25B+bm25B*length/averageLength))
+		}
+	}
+
+	return subjects, scores
+}
+
+// matchable returns an error if the values of predicate, using indexer, cannot
+// be searched with Match.
+func matchable(indexer Indexer, predicate string) error {
+	if _, ok := indexer.(SearchIndexer); !ok {
+		name := "default"
+		if indexer != nil {
+			name = indexer.name()
+		}
+
+		return fmt.Errorf("%w: %q uses %s indexer which can't be searched", ErrNotIndexed, predicate, name)
+	}
+
+	return nil
+}
+
+// sortByScore sorts subjects by their score, highest first unless asc. Subjects
+// with the same score keep their order.
+func sortByScore(subjects []uint64, scores map[uint64]float64, asc bool) {
+	sort.SliceStable(subjects, func(i, j int) bool {
+		if asc {
+			return scores[subjects[i]] < scores[subjects[j]]
+		}
+
+		return scores[subjects[i]] > scores[subjects[j]]
+	})
+}
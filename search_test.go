@@ -0,0 +1,167 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestSearchIndexerTerms(t *testing.T) {
+	assert.Equal(t, []string{"cafe", "open", "late"}, SearchIndexer{}.terms("The Cafè is OPEN late!"))
+	assert.Equal(t, []string{"go", "1", "23", "released"}, SearchIndexer{}.terms("Go 1.23 released"))
+
+	assert.Equal(t,
+		[]string{"run", "run", "run", "class", "pony", "generic", "hop"},
+		SearchIndexer{Stem: true}.terms("run runs running classes ponies generics hopped"))
+}
+
+func TestSearchIndexer(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"a", "content", "Generics in Go are finally here"},
+	))
+	assert.Nil(t, store.SetIndexer("content", SearchIndexer{Stem: true}))
+	assert.Nil(t, store.PutTriples(
+		Triple{"b", "content", "Using golang generics with golang maps in golang"},
+		Triple{"c", "content", "A long post about golang and the many things that it does, including generic functions, plus some other words to make it much longer"},
+		Triple{"d", "content", "Nothing to see here"},
+		Triple{"d", "name", "golang"},
+		Triple{"e", "content", "Golang generics"},
+		Triple{"e", "content", "A second value"},
+	))
+
+	t.Run("Match", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("content").Match("golang generics"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b", "c", "e"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("content").Match("generic"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "e"}, subjects)
+	})
+
+	t.Run("Match stop words only", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("content").Match("the and"))
+		assert.Nil(t, err)
+		assert.Len(t, subjects, 0)
+	})
+
+	t.Run("Sort by relevance", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(
+			Predicates("content").Match("golang generics"),
+			Sort("content").Relevance(),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b", "e", "c"}, subjects)
+
+		subjects, err = store.QuerySubjects(
+			Predicates("content").Match("golang generics"),
+			Sort("content").Relevance().Asc(),
+			Limit(1),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"c"}, subjects)
+	})
+
+	t.Run("Sort by relevance without Match", func(t *testing.T) {
+		_, err := store.QuerySubjects(Predicates("content"), Sort("content").Relevance())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Match with other predicates", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(
+			Predicates("content").Match("golang generics"),
+			Predicates("content").Eq("Golang generics"),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"e"}, subjects)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		triples, err := store.Query(Predicates("content").Match("golang generic"))
+		assert.Nil(t, err)
+		assert.Len(t, triples, 3)
+
+		triples, err = store.Query(Subjects("e"), Predicates("content").Match("second"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"e", "content", "A second value"}}, triples)
+	})
+
+	t.Run("Match not searchable", func(t *testing.T) {
+		_, err := store.QuerySubjects(Predicates("name").Match("golang"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+
+		_, err = store.Query(Predicates("name").Match("golang"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		assert.Nil(t, store.DeleteTriple("e", "content", "Golang generics"))
+		assert.Nil(t, store.Delete("b", "content"))
+		assert.Nil(t, store.DeleteSubject("a"))
+
+		subjects, err := store.QuerySubjects(Predicates("content").Match("generics"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"c"}, subjects)
+
+		subjects, err = store.QuerySubjects(Predicates("content").Match("second"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"e"}, subjects)
+	})
+
+	t.Run("removing indexer", func(t *testing.T) {
+		assert.Nil(t, store.SetIndexer("content", nil))
+
+		_, err := store.QuerySubjects(Predicates("content").Match("generics"))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+}
+
+func TestSearchIndexerLargeValues(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.SetIndexer("content", SearchIndexer{}))
+
+	// values too large to be used as keys
+	content := strings.Repeat("lorem ipsum dolor sit amet ", 2000) + "golang generics"
+	assert.True(t, len(content) > bbolt.MaxKeySize)
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"a", "content", content},
+		Triple{"b", "content", content},
+		Triple{"c", "content", "Short golang post"},
+	))
+
+	subjects, err := store.QuerySubjects(Predicates("content").Match("golang generics"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("content").Eq(content))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, subjects)
+
+	triples, err := store.Query(Subjects("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"a", "content", content}}, triples)
+
+	assert.Nil(t, store.DeleteTriple("a", "content", content))
+
+	subjects, err = store.QuerySubjects(Predicates("content").Match("generics"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b"}, subjects)
+}
@@ -1,22 +1,449 @@
 package no6
 
-// TODO: Schemas or something along those lines? Maybe don't call it schemas
-// because it won't be like a normal schema. Shapes?
-//
-// Anyway, I want to get rid of the ability to do (X, ?, Y) queries, as you
-// _should_ know the predicates you are looking for.
-//
-// Is a good plan to do struct tags?
-//
-//   type Person struct {
-//     Name string `no6:"name"`
-//     Age int `no6:"age"`
-//   }
-//
-// Then db.Query("john", &person, Eq, Anything) or something would fill in the gaps?
-//
-// Or have it as a plain list for now?
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// A shape describes how the fields of a struct map to the predicates of a
+// subject, using struct tags:
 //
-//   db.Query("john", []string{"name", "age"}, Eq, Anything) //=> current output
+//	type Person struct {
+//		ID      string   `no6:",subject"`
+//		Name    string   `no6:"name"`
+//		Age     int      `no6:"age"`
+//		Emails  []string `no6:"email"`
+//		Knows   []Person `no6:"knows,ref"`
+//		Company string   `no6:"company,ref"`
+//	}
 //
-// But that would mean all the type annoyingness.
+// Each tagged field is a predicate, and slices (other than []byte) have a value
+// for each element. Fields with their zero value are not written, as reading a
+// predicate without a value gives the zero value anyway. Fields marked "ref"
+// store a Ref: to the subject named by a string, or to a nested struct which is
+// written under the subject in its own "subject" field. A "subject" field is
+// filled with the subject when read. Fields without a tag, or tagged "-", are
+// ignored.
+
+// ErrNotFound is returned by GetStruct when the subject has no triples.
+var ErrNotFound = errors.New("no6: subject not found")
+
+type shapeField struct {
+	index     []int
+	name      string
+	predicate string
+	ref       bool
+	subject   bool
+}
+
+// shapeOf returns the tagged fields of the struct type t.
+func shapeOf(t reflect.Type) ([]shapeField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("no6: shape must be a struct, not %v", t)
+	}
+
+	var fields []shapeField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, ok := field.Tag.Lookup("no6")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		predicate, options, _ := strings.Cut(tag, ",")
+		shaped := shapeField{index: field.Index, name: field.Name, predicate: predicate}
+
+		for _, option := range strings.Split(options, ",") {
+			switch option {
+			case "ref":
+				shaped.ref = true
+			case "subject":
+				if field.Type.Kind() != reflect.String {
+					return nil, fmt.Errorf("no6: subject field %s.%s must be a string", t, field.Name)
+				}
+				shaped.subject = true
+			case "":
+			default:
+				return nil, fmt.Errorf("no6: unknown option %q for field %s.%s", option, t, field.Name)
+			}
+		}
+
+		if predicate == "" && !shaped.subject {
+			return nil, fmt.Errorf("no6: field %s.%s needs a predicate", t, field.Name)
+		}
+
+		fields = append(fields, shaped)
+	}
+
+	return fields, nil
+}
+
+// structOf returns the struct that v is, or points to.
+func structOf(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("no6: shape must be a struct, not %T", v)
+	}
+
+	return rv, nil
+}
+
+// multiValued returns true if values of type t are stored as a value for each
+// element.
+func multiValued(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
+
+// PutStruct writes a triple for each value of the tagged fields of v, a struct
+// or pointer to one, replacing any existing values of those predicates for
+// subject. Nested structs in fields marked "ref" are written too, replacing
+// their values in the same way, all in a single transaction.
+func (s *Store) PutStruct(subject string, v any) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.PutStruct(subject, v)
+	})
+}
+
+// putStruct writes v for subject, returning the subjects that were written.
+func (s *Store) putStruct(tx *bbolt.Tx, subject string, v any) (map[string]struct{}, error) {
+	rv, err := structOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	written := map[string]struct{}{}
+	return written, s.putShape(tx, subject, rv, written)
+}
+
+// putShape writes the struct rv for subject. Subjects in written are not written
+// again, so that structs referring to each other do not loop.
+func (s *Store) putShape(tx *bbolt.Tx, subject string, rv reflect.Value, written map[string]struct{}) error {
+	if _, ok := written[subject]; ok {
+		return nil
+	}
+	written[subject] = struct{}{}
+
+	fields, err := shapeOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.subject {
+			continue
+		}
+
+		if err := s.delete(tx, subject, field.predicate); err != nil {
+			return err
+		}
+
+		fv := rv.FieldByIndex(field.index)
+
+		var values []reflect.Value
+		if multiValued(fv.Type()) {
+			for i := 0; i < fv.Len(); i++ {
+				values = append(values, fv.Index(i))
+			}
+		} else if !fv.IsZero() {
+			values = []reflect.Value{fv}
+		}
+
+		for _, value := range values {
+			for value.Kind() == reflect.Pointer && !value.IsNil() {
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Pointer {
+				continue
+			}
+
+			object, err := s.shapeObject(tx, field, value, written)
+			if err != nil {
+				return fmt.Errorf("no6: field %s: %w", field.name, err)
+			}
+
+			if err := s.put(tx, subject, field.predicate, object); err != nil {
+				return fmt.Errorf("no6: field %s: %w", field.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// shapeObject returns the object to store for value, a value of field.
+func (s *Store) shapeObject(tx *bbolt.Tx, field shapeField, value reflect.Value, written map[string]struct{}) (any, error) {
+	if field.ref {
+		switch value.Kind() {
+		case reflect.String:
+			return Ref(value.String()), nil
+
+		case reflect.Struct:
+			nestedSubject, err := subjectOf(value)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.putShape(tx, nestedSubject, value, written); err != nil {
+				return nil, err
+			}
+
+			return Ref(nestedSubject), nil
+		}
+
+		return nil, fmt.Errorf("%v cannot be a ref", value.Type())
+	}
+
+	object := value.Interface()
+	if s.typer.understands(object) {
+		return object, nil
+	}
+
+	// types with a kind the Typer understands, like an int32 or a named string,
+	// are stored as that
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	}
+
+	return object, nil
+}
+
+// subjectOf returns the value of the "subject" field of the struct rv.
+func subjectOf(rv reflect.Value) (string, error) {
+	fields, err := shapeOf(rv.Type())
+	if err != nil {
+		return "", err
+	}
+
+	for _, field := range fields {
+		if field.subject {
+			if subject := rv.FieldByIndex(field.index).String(); subject != "" {
+				return subject, nil
+			}
+			break
+		}
+	}
+
+	return "", fmt.Errorf("%v needs a subject to be a ref", rv.Type())
+}
+
+// GetStruct fills the tagged fields of v, which must be a pointer to a struct,
+// with the values of subject. Fields without a value are set to their zero
+// value. The values of a slice are in the order they are stored, rather than
+// the order they were written. Nested structs in fields marked "ref" are read
+// too, all in a single transaction. If subject has no triples ErrNotFound is
+// returned.
+func (s *Store) GetStruct(subject string, v any) error {
+	return s.view(func(tx *bbolt.Tx) error {
+		return s.getStruct(tx, subject, v)
+	})
+}
+
+func (s *Store) getStruct(tx *bbolt.Tx, subject string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("no6: shape must be a pointer to a struct, not %T", v)
+	}
+
+	found, err := s.getShape(tx, subject, rv.Elem(), map[string]struct{}{})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// getShape fills the struct rv with the values of subject, returning false if
+// it has no triples. Subjects in read are not read again, so that structs
+// referring to each other do not loop.
+func (s *Store) getShape(tx *bbolt.Tx, subject string, rv reflect.Value, read map[string]struct{}) (bool, error) {
+	fields, err := shapeOf(rv.Type())
+	if err != nil {
+		return false, err
+	}
+
+	for _, field := range fields {
+		fv := rv.FieldByIndex(field.index)
+		fv.SetZero()
+
+		if field.subject {
+			fv.SetString(subject)
+		}
+	}
+
+	if _, ok := read[subject]; ok {
+		return true, nil
+	}
+	read[subject] = struct{}{}
+
+	triples, err := s.query(tx, Subjects(subject))
+	if err != nil {
+		return false, err
+	}
+	if len(triples) == 0 {
+		return false, nil
+	}
+
+	values := map[string][]any{}
+	for _, triple := range triples {
+		values[triple.Predicate] = append(values[triple.Predicate], triple.Object)
+	}
+
+	for _, field := range fields {
+		objects, ok := values[field.predicate]
+		if field.subject || !ok {
+			continue
+		}
+
+		fv := rv.FieldByIndex(field.index)
+
+		if multiValued(fv.Type()) {
+			for _, object := range objects {
+				elem := reflect.New(fv.Type().Elem()).Elem()
+				if err := s.setShapeField(tx, field, elem, object, read); err != nil {
+					return false, err
+				}
+				fv.Set(reflect.Append(fv, elem))
+			}
+		} else if err := s.setShapeField(tx, field, fv, objects[0], read); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// setShapeField sets fv, a value of field, to object.
+func (s *Store) setShapeField(tx *bbolt.Tx, field shapeField, fv reflect.Value, object any, read map[string]struct{}) error {
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := s.setShapeField(tx, field, ptr.Elem(), object, read); err != nil {
+			return err
+		}
+
+		fv.Set(ptr)
+		return nil
+	}
+
+	if field.ref && fv.Kind() == reflect.Struct {
+		ref, ok := object.(Ref)
+		if !ok {
+			return fmt.Errorf("%w: field %s: %T is not a ref", ErrTypeMismatch, field.name, object)
+		}
+
+		_, err := s.getShape(tx, string(ref), fv, read)
+		return err
+	}
+
+	if err := assignValue(fv, object); err != nil {
+		return fmt.Errorf("no6: field %s: %w", field.name, err)
+	}
+
+	return nil
+}
+
+// assignValue sets fv to value, converting between types of the same kind, or
+// between sizes of number.
+func assignValue(fv reflect.Value, value any) error {
+	rv := reflect.ValueOf(value)
+
+	if rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+
+	switch {
+	case isInt(rv.Kind()) && isInt(fv.Kind()):
+		if fv.OverflowInt(rv.Int()) {
+			return fmt.Errorf("%v overflows %v", value, fv.Type())
+		}
+		fv.SetInt(rv.Int())
+	case isUint(rv.Kind()) && isUint(fv.Kind()):
+		if fv.OverflowUint(rv.Uint()) {
+			return fmt.Errorf("%v overflows %v", value, fv.Type())
+		}
+		fv.SetUint(rv.Uint())
+	case rv.Kind() == reflect.Float64 && (fv.Kind() == reflect.Float32 || fv.Kind() == reflect.Float64):
+		fv.SetFloat(rv.Float())
+	case rv.Kind() == fv.Kind() && rv.Type().ConvertibleTo(fv.Type()):
+		fv.Set(rv.Convert(fv.Type()))
+	default:
+		return fmt.Errorf("%w: cannot set %v from %T", ErrTypeMismatch, fv.Type(), value)
+	}
+
+	return nil
+}
+
+func isInt(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUint(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uint64
+}
+
+// QueryInto finds the subjects that match all of matchers, like QuerySubjects,
+// and sets dst to a struct for each filled as by GetStruct. The dst must be a
+// pointer to a slice of structs, or of pointers to structs, and keeps the order
+// given by any Sort or Limit. It all happens in a single transaction.
+func (s *Store) QueryInto(dst any, matchers ...SubjectMatcher) error {
+	return s.view(func(tx *bbolt.Tx) error {
+		return s.queryInto(tx, dst, matchers...)
+	})
+}
+
+func (s *Store) queryInto(tx *bbolt.Tx, dst any, matchers ...SubjectMatcher) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("no6: query must be into a pointer to a slice, not %T", dst)
+	}
+
+	sliceType := rv.Elem().Type()
+	structType := sliceType.Elem()
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("no6: query must be into a slice of structs, not %v", sliceType)
+	}
+
+	subjects, err := s.querySubjects(tx, matchers...)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(sliceType, 0, len(subjects))
+	for _, subject := range subjects {
+		item := reflect.New(structType)
+		if _, err := s.getShape(tx, subject, item.Elem(), map[string]struct{}{}); err != nil {
+			return err
+		}
+
+		if sliceType.Elem().Kind() == reflect.Pointer {
+			result = reflect.Append(result, item)
+		} else {
+			result = reflect.Append(result, item.Elem())
+		}
+	}
+
+	rv.Elem().Set(result)
+	return nil
+}
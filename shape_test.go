@@ -0,0 +1,199 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"hawx.me/code/assert"
+)
+
+type testStatus string
+
+type testCompany struct {
+	ID   string `no6:",subject"`
+	Name string `no6:"name"`
+}
+
+type testPerson struct {
+	ID       string         `no6:",subject"`
+	Name     string         `no6:"name"`
+	Age      int32          `no6:"age"`
+	Height   float32        `no6:"height"`
+	Admin    bool           `no6:"admin"`
+	Born     time.Time      `no6:"born"`
+	Status   testStatus     `no6:"status"`
+	Emails   []string       `no6:"email"`
+	Nickname *string        `no6:"nickname"`
+	Employer *testCompany   `no6:"employer,ref"`
+	Friends  []testPerson   `no6:"knows,ref"`
+	Manager  string         `no6:"manager,ref"`
+	Home     Point          `no6:"home"`
+	Notes    map[string]int `no6:"-"`
+	ignored  string
+}
+
+func TestStruct(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	nickname := "Al"
+	alice := testPerson{
+		Name:     "Alice",
+		Age:      31,
+		Height:   1.5,
+		Admin:    true,
+		Born:     time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC),
+		Status:   "active",
+		Emails:   []string{"alice@example.com", "a@example.com"},
+		Nickname: &nickname,
+		Employer: &testCompany{ID: "acme", Name: "Acme"},
+		Friends: []testPerson{
+			{ID: "bob", Name: "Bob", Age: 25},
+		},
+		Manager: "carol",
+		Home:    london,
+		Notes:   map[string]int{"a": 1},
+	}
+
+	assert.Nil(t, store.PutStruct("alice", &alice))
+
+	t.Run("triples", func(t *testing.T) {
+		triples, err := store.Query(Subjects("alice"), Predicates("age", "email", "employer", "manager", "status"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{
+			{"alice", "age", 31},
+			{"alice", "email", "alice@example.com"},
+			{"alice", "email", "a@example.com"},
+			{"alice", "employer", Ref("acme")},
+			{"alice", "manager", Ref("carol")},
+			{"alice", "status", "active"},
+		}, triples)
+
+		triples, err = store.Query(Subjects("acme"))
+		assert.Nil(t, err)
+		assert.Equal(t, []Triple{{"acme", "name", "Acme"}}, triples)
+	})
+
+	t.Run("GetStruct", func(t *testing.T) {
+		var read testPerson
+		assert.Nil(t, store.GetStruct("alice", &read))
+
+		expected := alice
+		expected.ID = "alice"
+		expected.Notes = nil
+		expected.Friends = []testPerson{{ID: "bob", Name: "Bob", Age: 25}}
+		assert.Equal(t, expected, read)
+	})
+
+	t.Run("replaces values", func(t *testing.T) {
+		alice.Emails = []string{"alice@example.org"}
+		alice.Nickname = nil
+		assert.Nil(t, store.PutStruct("alice", alice))
+
+		var read testPerson
+		assert.Nil(t, store.GetStruct("alice", &read))
+		assert.Equal(t, []string{"alice@example.org"}, read.Emails)
+		assert.Nil(t, read.Nickname)
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		bob := testPerson{ID: "bob", Name: "Bob", Friends: []testPerson{{ID: "bob", Name: "Bob"}}}
+		assert.Nil(t, store.PutStruct("bob", bob))
+
+		var read testPerson
+		assert.Nil(t, store.GetStruct("bob", &read))
+		assert.Equal(t, "Bob", read.Name)
+		assert.Equal(t, []testPerson{{ID: "bob"}}, read.Friends)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		var read testPerson
+		assert.True(t, errors.Is(store.GetStruct("nobody", &read), ErrNotFound))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.NotNil(t, store.PutStruct("x", "not a struct"))
+		assert.NotNil(t, store.GetStruct("alice", testPerson{}))
+
+		assert.NotNil(t, store.PutStruct("x", testPerson{Friends: []testPerson{{Name: "No ID"}}}))
+		var read testPerson
+		assert.True(t, errors.Is(store.GetStruct("x", &read), ErrNotFound))
+
+		assert.NotNil(t, store.PutStruct("x", struct {
+			Bad chan int `no6:"bad"`
+		}{make(chan int)}))
+
+		var small struct {
+			Age int8 `no6:"age"`
+		}
+		assert.Nil(t, store.Put("old", "age", 300))
+		assert.NotNil(t, store.GetStruct("old", &small))
+
+		var wrong struct {
+			Age string `no6:"age"`
+		}
+		assert.True(t, errors.Is(store.GetStruct("old", &wrong), ErrTypeMismatch))
+
+		var notRef struct {
+			Manager testCompany `no6:"age,ref"`
+		}
+		assert.True(t, errors.Is(store.GetStruct("old", &notRef), ErrTypeMismatch))
+	})
+}
+
+func TestQueryInto(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	acme := &testCompany{ID: "acme", Name: "Acme"}
+	for _, person := range []testPerson{
+		{ID: "alice", Name: "Alice", Age: 31, Employer: acme},
+		{ID: "bob", Name: "Bob", Age: 25},
+		{ID: "carol", Name: "Carol", Age: 40, Employer: acme},
+	} {
+		assert.Nil(t, store.PutStruct(person.ID, person))
+	}
+
+	t.Run("structs", func(t *testing.T) {
+		var people []testPerson
+		assert.Nil(t, store.QueryInto(&people, Predicates("age").Gt(30), Sort("age").Desc()))
+		assert.Equal(t, []testPerson{
+			{ID: "carol", Name: "Carol", Age: 40, Employer: &testCompany{ID: "acme", Name: "Acme"}},
+			{ID: "alice", Name: "Alice", Age: 31, Employer: &testCompany{ID: "acme", Name: "Acme"}},
+		}, people)
+	})
+
+	t.Run("pointers", func(t *testing.T) {
+		var people []*testPerson
+		assert.Nil(t, store.QueryInto(&people, Predicates("age"), Sort("age"), Limit(1)))
+		assert.Equal(t, []*testPerson{{ID: "bob", Name: "Bob", Age: 25}}, people)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		people := []testPerson{{ID: "old"}}
+		assert.Nil(t, store.QueryInto(&people, Predicates("age").Gt(100)))
+		assert.Len(t, people, 0)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var people []testPerson
+		assert.NotNil(t, store.QueryInto(people, Predicates("age")))
+
+		var names []string
+		assert.NotNil(t, store.QueryInto(&names, Predicates("age")))
+
+		_, err := store.QuerySubjects(Predicates("age").Match("x"))
+		assert.True(t, errors.Is(store.QueryInto(&people, Predicates("age").Match("x")), ErrNotIndexed))
+		assert.True(t, errors.Is(err, ErrNotIndexed))
+	})
+}
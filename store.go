@@ -6,6 +6,8 @@ package no6
 import (
 	"log/slog"
 	"os"
+	"sync"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
@@ -13,21 +15,32 @@ import (
 const Anything = "__Anything__"
 
 var (
+	// The meta bucket records information about the database itself, currently
+	// only the version of the format it is written in.
+	bucketMeta = []byte("meta")
+	keyVersion = []byte("version")
+
 	// The id bucket contains a single record, the last ID that was used. This
 	// allows assigning a newly incremented ID for each subject and object.
 	bucketID = []byte("id")
 	keyLast  = []byte("last")
 
-	// The data bucket contains mappings of each ID (should it be merged with
-	// above?) to the value it represents. This is maintained in both
-	// directions. So a value X will mean the bucket contains (X, ID(X)), and
-	// (ID(X), X) pairs.
-	//
-	// TODO: is it necessary to have both, and what about collisions?
+	// The dictionary maps each value to the ID that represents it in posting
+	// lists, and back again. Subjects (nodes) and objects (literals) are kept in
+	// separate namespaces, so the subject "x" and the string "x" are different
+	// values with different IDs.
 	//
-	// TODO: would be good to have some indexers, so we can store other things. So
-	// have for indexer f, store (f(X), ID(X)) and (ID(X), X) pairs.
-	bucketData = []byte("data")
+	// The nodes bucket contains (X, ID(X)) pairs for each subject, and the
+	// literals bucket (Format(X), ID(X)) pairs for each object. The values bucket
+	// contains the reverse, (ID(X), N+X) pairs where N is the namespace of X.
+	bucketNodes    = []byte("nodes")
+	bucketLiterals = []byte("literals")
+	bucketValues   = []byte("values")
+
+	// The hashes bucket contains (Hash(Format(X)), ID(X)) pairs for objects of
+	// predicates using the HashIndexer, so that large values are not used as
+	// keys.
+	bucketHashes = []byte("hashes")
 
 	// The predicates bucket specifies all predicates (as keys), to support
 	// querying over all predicates.
@@ -44,25 +57,128 @@ type Triple struct {
 }
 
 type Store struct {
+	// mu is held for writing while db is being replaced by compact, and for
+	// reading by every transaction.
+	mu     sync.RWMutex
 	db     *bbolt.DB
 	logger *slog.Logger
 	typer  *Typer
+	shapes shapeCache
+
+	// fileMode and options are kept so the database can be reopened after
+	// compacting.
+	fileMode os.FileMode
+	options  *bbolt.Options
+}
+
+// Options configure how a Store is opened.
+type Options struct {
+	// Logger is given debug output for each write. If nil nothing is logged.
+	Logger *slog.Logger
+
+	// ReadOnly opens the database with a shared lock, so that many processes can
+	// read from it at once. All writes will fail.
+	//
+	// It does not allow reading while another process has the database open for
+	// writing, as that process holds an exclusive lock on the file until it is
+	// closed. Opening will wait for the writer to close, or fail with
+	// bbolt.ErrTimeout once Timeout has passed. To read alongside a writer have it
+	// write a copy with Snapshot, and open that instead.
+	ReadOnly bool
+
+	// Timeout is how long to wait to obtain a lock on the file. If zero it will
+	// wait indefinitely.
+	Timeout time.Duration
+
+	// NoSync skips the fsync after each commit. This makes writes faster, but
+	// risks losing data if the system crashes.
+	NoSync bool
+
+	// FileMode is used when the file needs creating. If zero 0600 is used.
+	FileMode os.FileMode
 }
 
+// Open returns a Store using the file at path, creating it if it does not exist.
+// If the file was written in an older format it is migrated.
 func Open(path string) (*Store, error) {
-	db, err := bbolt.Open(path, 0600, nil)
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions returns a Store using the file at path, configured by opts.
+func OpenWithOptions(path string, opts Options) (*Store, error) {
+	mode := opts.FileMode
+	if mode == 0 {
+		mode = 0600
+	}
+
+	options := &bbolt.Options{
+		ReadOnly:     opts.ReadOnly,
+		Timeout:      opts.Timeout,
+		NoSync:       opts.NoSync,
+		FreelistType: bbolt.FreelistArrayType,
+	}
+
+	db, err := bbolt.Open(path, mode, options)
 	if err != nil {
 		return nil, err
 	}
 
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		// Level: slog.LevelDebug,
-	})
-	logger := slog.New(handler)
+	if err := migrate(db, opts.ReadOnly); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
 
 	return &Store{
-		db:     db,
-		logger: logger,
-		typer:  &Typer{},
+		db:       db,
+		logger:   logger,
+		typer:    &Typer{},
+		fileMode: mode,
+		options:  options,
 	}, nil
 }
+
+// Close releases the file used by the Store. Any open transactions must be
+// finished before calling Close.
+func (s *Store) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Close()
+}
+
+// view runs fn within a read-only transaction on the database.
+func (s *Store) view(fn func(*bbolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.View(fn)
+}
+
+// update runs fn within a read-write transaction on the database.
+func (s *Store) update(fn func(*bbolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Update(fn)
+}
+
+// Snapshot writes a consistent copy of the database to a new file at path, as
+// it is at the start of a read transaction. Other processes can open the copy,
+// including with ReadOnly, while the Store continues to be written to.
+func (s *Store) Snapshot(path string) error {
+	return s.view(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(path, s.fileMode)
+	})
+}
+
+// RegisterType sets codec to be used for storing values with the same Go type
+// as value. Codecs are not recorded in the database, so must be registered
+// each time it is opened before any values using them are read or written.
+func (s *Store) RegisterType(value any, codec Codec) error {
+	return s.typer.RegisterType(value, codec)
+}
@@ -1,8 +1,10 @@
 package no6
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"go.etcd.io/bbolt"
 	"hawx.me/code/assert"
@@ -57,7 +59,7 @@ func BenchmarkStoreQuery(b *testing.B) {
 	)
 
 	for n := 0; n < b.N; n++ {
-		benchTriples = store.Query(Predicates("knows"))
+		benchTriples, _ = store.Query(Predicates("knows"))
 	}
 }
 
@@ -81,48 +83,58 @@ func TestSimpleQuery(t *testing.T) {
 
 	// * P *
 	t.Run("predicate", func(t *testing.T) {
+		triples, err := store.Query(Predicates("knows"))
+		assert.Nil(t, err)
 		assert.Equal(t,
 			[]Triple{{"john", "knows", "dave"}, {"john", "knows", "mike"}},
-			store.Query(Predicates("knows")),
+			triples,
 		)
 	})
 
 	// S P *
 	t.Run("subject-predicate", func(t *testing.T) {
+		triples, err := store.Query(Subjects("john"), Predicates("age"))
+		assert.Nil(t, err)
 		assert.Equal(t,
 			[]Triple{{"john", "age", 20}},
-			store.Query(Subjects("john"), Predicates("age")),
+			triples,
 			// store.QueryValues(Subjects("john"), Predicates("age")) => []any{20}
 		)
 	})
 
 	// * P O
 	t.Run("predicate-object", func(t *testing.T) {
+		triples, err := store.Query(Predicates("age").Eq(30))
+		assert.Nil(t, err)
 		assert.Equal(t,
 			[]Triple{{"dave", "age", 30}},
-			store.Query(Predicates("age").Eq(30)),
+			triples,
 			// store.QuerySubjects(Predicates("age").Eq(30)) => []string{"save"}
 		)
 	})
 
 	// S * O
 	t.Run("subject-object", func(t *testing.T) {
+		triples, err := store.Query(Predicates("age", "knows", "firstName", "lastName").Eq(30))
+		assert.Nil(t, err)
 		assert.Equal(t,
 			[]Triple{{"dave", "age", 30}},
-			store.Query(Predicates("age", "knows", "firstName", "lastName").Eq(30)),
+			triples,
 		)
 		// store.QueryHas(Predicates("age", "knows", "firstName", "lastName").Eq(30)) => true
 	})
 
 	// S * *
 	t.Run("subject", func(t *testing.T) {
+		triples, err := store.Query(Subjects("dave"), Predicates("age", "knows", "firstName", "lastName"))
+		assert.Nil(t, err)
 		assert.Equal(t,
 			[]Triple{
 				{"dave", "age", 30},
 				{"dave", "firstName", "Dave"},
 				{"dave", "lastName", "Davidson"},
 			},
-			store.Query(Subjects("dave"), Predicates("age", "knows", "firstName", "lastName")),
+			triples,
 			// store.Query(Subjects("dave"), Predicates("age", "knows", "firstName", "lastName"))
 		)
 	})
@@ -169,12 +181,14 @@ func TestQuerySortLimit(t *testing.T) {
 		Triple{"e", "size", 3},
 	)
 
+	subjects, err := store.QuerySubjects(
+		Predicates("size"),
+		Sort("size"),
+		Limit(4),
+	)
+	assert.Nil(t, err)
 	assert.Equal(t, []string{"a", "c", "e", "b"},
-		store.QuerySubjects(
-			Predicates("size"),
-			Sort("size"),
-			Limit(4),
-		),
+		subjects,
 	)
 }
 
@@ -195,14 +209,69 @@ func TestQuerySubject(t *testing.T) {
 		Triple{"adam", "eats", "thai"},
 	)
 
+	subjects, err := store.QuerySubjects(
+		Predicates("lives-in").Eq("sf"),
+		Predicates("eats").Eq("sushi"),
+	)
+	assert.Nil(t, err)
 	assert.Equal(t, []string{"john"},
-		store.QuerySubjects(
-			Predicates("lives-in").Eq("sf"),
-			Predicates("eats").Eq("sushi"),
-		),
+		subjects,
 	)
 }
 
+func TestQuerySubjectConstraints(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(
+		Triple{"a", "tag", "go"},
+		Triple{"a", "tag", "rust"},
+		Triple{"b", "tag", "go"},
+		Triple{"c", "tag", "rust"},
+		Triple{"d", "tag", "go"},
+		Triple{"d", "draft", "true"},
+	)
+
+	t.Run("Eq", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Eq("go"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "d"}, subjects)
+	})
+
+	t.Run("Eq missing", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Eq("zig"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string(nil), subjects)
+	})
+
+	t.Run("Ne", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Ne("go"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "c"}, subjects)
+	})
+
+	t.Run("Ne missing", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Ne("zig"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, subjects)
+	})
+
+	t.Run("Without", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Eq("go"), Without("draft"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, subjects)
+	})
+
+	t.Run("Without missing predicate", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Predicates("tag").Eq("go"), Without("deleted"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "d"}, subjects)
+	})
+}
+
 func TestQuerySorting(t *testing.T) {
 	file, _ := os.CreateTemp("", "")
 	file.Close()
@@ -220,34 +289,42 @@ func TestQuerySorting(t *testing.T) {
 	)
 
 	t.Run("Eq", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Eq("3"))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", "3"},
-		}, store.Query(Predicates("count").Eq("3")))
+		}, triples)
 	})
 
 	t.Run("Ne", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Ne("3"))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", "1"},
 			{"x", "count", "5"},
 			{"y", "count", "2"},
 			{"y", "count", "4"},
 			{"y", "count", "6"},
-		}, store.Query(Predicates("count").Ne("3")))
+		}, triples)
 	})
 
 	t.Run("Lt", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Lt("3"))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", "1"},
 			{"y", "count", "2"},
-		}, store.Query(Predicates("count").Lt("3")))
+		}, triples)
 	})
 
 	t.Run("Gt", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Gt("3"))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", "5"},
 			{"y", "count", "4"},
 			{"y", "count", "6"},
-		}, store.Query(Predicates("count").Gt("3")))
+		}, triples)
 	})
 }
 
@@ -268,34 +345,42 @@ func TestQueryIntSorting(t *testing.T) {
 	)
 
 	t.Run("Eq", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Eq(3))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", 3},
-		}, store.Query(Predicates("count").Eq(3)))
+		}, triples)
 	})
 
 	t.Run("Ne", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Ne(3))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", 1},
 			{"x", "count", 5},
 			{"y", "count", 2},
 			{"y", "count", 4},
 			{"y", "count", 6},
-		}, store.Query(Predicates("count").Ne(3)))
+		}, triples)
 	})
 
 	t.Run("Lt", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Lt(3))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", 1},
 			{"y", "count", 2},
-		}, store.Query(Predicates("count").Lt(3)))
+		}, triples)
 	})
 
 	t.Run("Gt", func(t *testing.T) {
+		triples, err := store.Query(Predicates("count").Gt(3))
+		assert.Nil(t, err)
 		assert.Equal(t, []Triple{
 			{"x", "count", 5},
 			{"y", "count", 4},
 			{"y", "count", 6},
-		}, store.Query(Predicates("count").Gt(3)))
+		}, triples)
 	})
 }
 
@@ -328,3 +413,115 @@ func TestUseCaseMicropub(t *testing.T) {
 		Triple{"uid4", "unit", "%"},
 	)
 }
+
+func TestOpenReadOnly(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+	store.PutTriples(Triple{"john", "firstName", "John"})
+
+	_, err = OpenWithOptions(file.Name(), Options{ReadOnly: true, Timeout: 10 * time.Millisecond})
+	assert.Equal(t, bbolt.ErrTimeout, err)
+
+	assert.Nil(t, store.Close())
+
+	readerA, err := OpenWithOptions(file.Name(), Options{ReadOnly: true, Timeout: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	defer readerA.Close()
+
+	readerB, err := OpenWithOptions(file.Name(), Options{ReadOnly: true, Timeout: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	defer readerB.Close()
+
+	triples, err := readerA.Query(Subjects("john"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"john", "firstName", "John"}}, triples)
+
+	triples, err = readerB.Query(Subjects("john"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"john", "firstName", "John"}}, triples)
+
+	assert.Equal(t, bbolt.ErrDatabaseReadOnly, readerA.Put("john", "lastName", "Smith"))
+}
+
+func TestSnapshot(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	snapshot := file.Name() + ".snapshot"
+	defer os.Remove(snapshot)
+
+	store, err := Open(file.Name())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	store.PutTriples(Triple{"john", "firstName", "John"})
+	assert.Nil(t, store.Snapshot(snapshot))
+
+	reader, err := OpenWithOptions(snapshot, Options{ReadOnly: true, Timeout: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	assert.Nil(t, store.Put("john", "lastName", "Smith"))
+
+	triples, err := reader.Query(Subjects("john"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"john", "firstName", "John"}}, triples)
+}
+
+func TestTypeErrors(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file, _ := os.CreateTemp("", "")
+			file.Close()
+			defer os.Remove(file.Name())
+
+			store, _ := Open(file.Name())
+			defer store.Close()
+
+			if indexed {
+				assert.Nil(t, store.SetIndexer("age", FullTextIndexer{}))
+				assert.Nil(t, store.SetIndexer("name", FullTextIndexer{}))
+			}
+
+			assert.Nil(t, store.PutTriples(
+				Triple{"a", "name", "alice"},
+				Triple{"b", "name", "bob"},
+				Triple{"a", "age", 30},
+				Triple{"b", "age", "unknown"},
+			))
+
+			t.Run("Put", func(t *testing.T) {
+				err := store.Put("c", "age", int64(5))
+				assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+				err = store.PutTriples(Triple{"c", "name", "carol"}, Triple{"c", "age", struct{}{}})
+				assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+				subjects, err := store.QuerySubjects(Predicates("name"))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"a", "b"}, subjects)
+			})
+
+			t.Run("unsupported constraint", func(t *testing.T) {
+				_, err := store.QuerySubjects(Predicates("name").Eq(int64(5)))
+				assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+				_, err = store.Query(Predicates("name").Eq(int64(5)))
+				assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+				err = store.DeleteTriple("a", "name", int64(5))
+				assert.True(t, errors.Is(err, ErrUnsupportedType))
+			})
+		})
+	}
+}
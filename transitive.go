@@ -0,0 +1,158 @@
+package no6
+
+import (
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// Reached is a subject found by Reachable.
+type Reached struct {
+	Subject string
+	// Depth is the number of links followed to reach the subject, so those
+	// linked to directly from the start are at depth 1.
+	Depth int
+}
+
+// Reachable returns the subjects that can be reached from start by following
+// Ref objects of predicate, nearest first. Subjects are only returned once, at
+// the smallest depth they are found, so cycles are not followed, and start is
+// not returned even if a cycle leads back to it. If maxDepth is greater than
+// zero links are only followed that many times, it must not be negative. Like
+// any other query, nothing is returned if predicate has never been written to.
+// So
+//
+//	store.Reachable("post", "inReplyTo", 0)
+//
+// returns every post in the thread above "post".
+func (s *Store) Reachable(start, predicate string, maxDepth int) ([]Reached, error) {
+	var val []Reached
+
+	err := s.view(func(tx *bbolt.Tx) (err error) {
+		val, err = s.reachable(tx, start, predicate, maxDepth)
+		return err
+	})
+
+	return val, err
+}
+
+func (s *Store) reachable(tx *bbolt.Tx, start, predicate string, maxDepth int) ([]Reached, error) {
+	if maxDepth < 0 {
+		return nil, fmt.Errorf("no6: max depth must not be negative, got %d", maxDepth)
+	}
+
+	dict := s.readDictionary(tx)
+
+	startUID := dict.nodeUID(start)
+	predicateBucket := tx.Bucket([]byte("predicate-" + predicate))
+	if startUID == nil || predicateBucket == nil {
+		return nil, nil
+	}
+
+	var (
+		reached []Reached
+		err     error
+	)
+	walk(readUID(startUID), maxDepth, func(subject uint64) []uint64 {
+		postingList := predicateBucket.Get(makeKey(subject, predicate))
+
+		var linked []uint64
+		for i := 0; i < len(postingList); i += 8 {
+			if ns, _ := dict.value(postingList[i : i+8]); ns == namespaceNode {
+				linked = append(linked, readUID(postingList[i:i+8]))
+			}
+		}
+
+		return linked
+	}, func(subject uint64, depth int) {
+		name, ok := dict.node(writeUID(subject))
+		if !ok && err == nil {
+			err = fmt.Errorf("no6: no subject for uid %d", subject)
+		}
+
+		reached = append(reached, Reached{Subject: name, Depth: depth})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reached, nil
+}
+
+// walk visits the subjects linked from start, breadth first, calling visit for
+// each the first time it is found. The subjects linked from each are given by
+// next, and if maxDepth is greater than zero no more than that many links are
+// followed.
+func walk(start uint64, maxDepth int, next func(uint64) []uint64, visit func(uint64, int)) {
+	seen := map[uint64]struct{}{start: {}}
+	frontier := []uint64{start}
+
+	for depth := 1; len(frontier) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var nextFrontier []uint64
+
+		for _, subject := range frontier {
+			for _, linked := range next(subject) {
+				if _, ok := seen[linked]; ok {
+					continue
+				}
+				seen[linked] = struct{}{}
+
+				visit(linked, depth)
+				nextFrontier = append(nextFrontier, linked)
+			}
+		}
+
+		frontier = nextFrontier
+	}
+}
+
+type TransitiveMatcher struct {
+	predicate string
+	subject   string
+	maxDepth  int
+}
+
+// Transitive returns a matcher that follows Ref objects of predicate any number
+// of times. It must be given a subject to reach with Eq.
+func Transitive(predicate string) TransitiveMatcher {
+	return TransitiveMatcher{predicate: predicate}
+}
+
+func (q TransitiveMatcher) isSubjectMatcher() {}
+
+// Eq returns a matcher that matches the subjects that can reach subject by
+// following the predicate. So
+//
+//	Transitive("parent").Eq("root")
+//
+// matches everything below "root", however far. The subject itself is not
+// matched, even if a cycle leads back to it.
+func (q TransitiveMatcher) Eq(subject string) TransitiveMatcher {
+	q.subject = subject
+	return q
+}
+
+// MaxDepth returns a matcher that follows the predicate no more than depth
+// times. It must not be negative.
+func (q TransitiveMatcher) MaxDepth(depth int) TransitiveMatcher {
+	q.maxDepth = depth
+	return q
+}
+
+// subjectsReaching returns the subjects that can reach the subject of q, found
+// by walking the reverse index of its predicate.
+func subjectsReaching(tx *bbolt.Tx, dict *dictionary, q TransitiveMatcher) []uint64 {
+	subjectUID := dict.nodeUID(q.subject)
+	if subjectUID == nil {
+		return nil
+	}
+
+	var subjects []uint64
+	walk(readUID(subjectUID), q.maxDepth, func(subject uint64) []uint64 {
+		return subjectsWith(tx, q.predicate, writeUID(subject))
+	}, func(subject uint64, _ int) {
+		subjects = append(subjects, subject)
+	})
+
+	return sortUnique(subjects)
+}
@@ -0,0 +1,111 @@
+package no6
+
+import (
+	"os"
+	"testing"
+
+	"hawx.me/code/assert"
+)
+
+func TestReachable(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"go", "parent", Ref("languages")},
+		Triple{"rust", "parent", Ref("languages")},
+		Triple{"languages", "parent", Ref("computing")},
+		Triple{"computing", "parent", Ref("root")},
+		Triple{"root", "name", "Root"},
+		Triple{"gophers", "parent", Ref("go")},
+		Triple{"gophers", "parent", Ref("animals")},
+		Triple{"animals", "parent", Ref("root")},
+		Triple{"animals", "parent", "not a ref"},
+
+		// a cycle
+		Triple{"a", "knows", Ref("b")},
+		Triple{"b", "knows", Ref("c")},
+		Triple{"c", "knows", Ref("a")},
+	))
+
+	t.Run("Reachable", func(t *testing.T) {
+		reached, err := store.Reachable("gophers", "parent", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []Reached{
+			{"go", 1},
+			{"animals", 1},
+			{"languages", 2},
+			{"root", 2},
+			{"computing", 3},
+		}, reached)
+
+		reached, err = store.Reachable("gophers", "parent", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, []Reached{{"go", 1}, {"animals", 1}}, reached)
+
+		reached, err = store.Reachable("root", "parent", 0)
+		assert.Nil(t, err)
+		assert.Len(t, reached, 0)
+
+		reached, err = store.Reachable("missing", "parent", 0)
+		assert.Nil(t, err)
+		assert.Len(t, reached, 0)
+	})
+
+	t.Run("Reachable errors", func(t *testing.T) {
+		_, err := store.Reachable("gophers", "parent", -1)
+		assert.NotNil(t, err)
+
+	})
+
+	t.Run("Reachable unknown predicate", func(t *testing.T) {
+		reached, err := store.Reachable("gophers", "missing", 0)
+		assert.Nil(t, err)
+		assert.Len(t, reached, 0)
+
+		store.View(func(tx *Tx) error {
+			reached, err = tx.Reachable("gophers", "missing", 0)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Len(t, reached, 0)
+
+		subjects, err := store.QuerySubjects(Transitive("missing").Eq("gophers"))
+		assert.Nil(t, err)
+		assert.Len(t, subjects, 0)
+	})
+
+	t.Run("Reachable with cycle", func(t *testing.T) {
+		reached, err := store.Reachable("a", "knows", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []Reached{{"b", 1}, {"c", 2}}, reached)
+	})
+
+	t.Run("Transitive", func(t *testing.T) {
+		subjects, err := store.QuerySubjects(Transitive("parent").Eq("languages"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"go", "rust", "gophers"}, subjects)
+
+		subjects, err = store.QuerySubjects(Transitive("parent").Eq("root").MaxDepth(2))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"languages", "computing", "gophers", "animals"}, subjects)
+
+		subjects, err = store.QuerySubjects(Transitive("parent").Eq("computing"), Transitive("parent").Eq("animals"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"gophers"}, subjects)
+
+		subjects, err = store.QuerySubjects(Transitive("knows").Eq("a"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"b", "c"}, subjects)
+
+		_, err = store.QuerySubjects(Transitive("parent"))
+		assert.NotNil(t, err)
+
+		_, err = store.QuerySubjects(Transitive("parent").Eq("root").MaxDepth(-1))
+		assert.NotNil(t, err)
+	})
+}
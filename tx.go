@@ -0,0 +1,121 @@
+package no6
+
+import "go.etcd.io/bbolt"
+
+// A Tx groups operations on the store so that they all see, and write to, the
+// same state. Any writes are committed together when the function given to
+// Update returns nil, or are all rolled back if it returns an error.
+//
+// A Tx must not be used after the function it was given to has returned.
+type Tx struct {
+	tx    *bbolt.Tx
+	store *Store
+
+	// written are the subjects that have been changed, which are checked against
+	// their shapes before committing.
+	written map[string]struct{}
+}
+
+// Update runs fn within a read-write transaction. Once fn returns each subject
+// that was written is checked against the shapes for its types, and if any do
+// not fit nothing is committed and the violations are returned.
+func (s *Store) Update(fn func(*Tx) error) error {
+	return s.update(func(tx *bbolt.Tx) error {
+		t := &Tx{tx: tx, store: s, written: map[string]struct{}{}}
+		if err := fn(t); err != nil {
+			return err
+		}
+
+		return s.validate(tx, t.written)
+	})
+}
+
+// View runs fn within a read-only transaction. Any attempt to write with the Tx
+// will return an error.
+func (s *Store) View(fn func(*Tx) error) error {
+	return s.view(func(tx *bbolt.Tx) error {
+		return fn(&Tx{tx: tx, store: s})
+	})
+}
+
+func (t *Tx) Put(subject, predicate string, object any) error {
+	t.write(subject)
+	return t.store.put(t.tx, subject, predicate, object)
+}
+
+func (t *Tx) PutTriples(triples ...Triple) error {
+	for _, triple := range triples {
+		if err := t.Put(triple.Subject, triple.Predicate, triple.Object); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Tx) Delete(subject, predicate string) error {
+	t.write(subject)
+	return t.store.delete(t.tx, subject, predicate)
+}
+
+func (t *Tx) DeleteTriple(subject, predicate string, object any) error {
+	t.write(subject)
+	return t.store.deleteTriple(t.tx, subject, predicate, object)
+}
+
+func (t *Tx) DeleteSubject(subject string) error {
+	return t.store.deleteSubject(t.tx, subject)
+}
+
+// write records that subject has been changed. Within View there is nothing to
+// check, as any write will fail.
+func (t *Tx) write(subject string) {
+	if t.written != nil {
+		t.written[subject] = struct{}{}
+	}
+}
+
+// Query returns the results matching the given matchers.
+func (t *Tx) Query(matchers ...Matcher) ([]Triple, error) {
+	return t.store.query(t.tx, matchers...)
+}
+
+// QuerySubjects finds subjects that match all of the given matchers.
+func (t *Tx) QuerySubjects(matchers ...SubjectMatcher) ([]string, error) {
+	return t.store.querySubjects(t.tx, matchers...)
+}
+
+// Incoming returns the triples with a Ref to object, for any of predicates or
+// for every predicate if none are given.
+func (t *Tx) Incoming(object string, predicates ...string) ([]Triple, error) {
+	return t.store.incoming(t.tx, object, predicates)
+}
+
+// Reachable returns the subjects that can be reached from start by following
+// Ref objects of predicate, nearest first.
+func (t *Tx) Reachable(start, predicate string, maxDepth int) ([]Reached, error) {
+	return t.store.reachable(t.tx, start, predicate, maxDepth)
+}
+
+// PutStruct writes a triple for each value of the tagged fields of v, replacing
+// any existing values of those predicates for subject.
+func (t *Tx) PutStruct(subject string, v any) error {
+	written, err := t.store.putStruct(t.tx, subject, v)
+	for subject := range written {
+		t.write(subject)
+	}
+
+	return err
+}
+
+// GetStruct fills the tagged fields of v, which must be a pointer to a struct,
+// with the values of subject.
+func (t *Tx) GetStruct(subject string, v any) error {
+	return t.store.getStruct(t.tx, subject, v)
+}
+
+// QueryInto finds the subjects that match all of matchers, and sets dst to a
+// struct for each filled as by GetStruct.
+func (t *Tx) QueryInto(dst any, matchers ...SubjectMatcher) error {
+	return t.store.queryInto(t.tx, dst, matchers...)
+}
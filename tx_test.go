@@ -0,0 +1,104 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestUpdate(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	err := store.Update(func(tx *Tx) error {
+		if err := tx.Put("john", "firstName", "John"); err != nil {
+			return err
+		}
+		if err := tx.Put("john", "age", 20); err != nil {
+			return err
+		}
+
+		subjects, err := tx.QuerySubjects(Predicates("age").Eq(20))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"john"}, subjects)
+
+		return tx.Delete("john", "age")
+	})
+	assert.Nil(t, err)
+
+	triples, err := store.Query(Subjects("john"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"john", "firstName", "John"}},
+		triples,
+	)
+}
+
+func TestUpdateRollback(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(
+		Triple{"john", "firstName", "John"},
+		Triple{"john", "lastName", "Smith"},
+	)
+
+	failure := errors.New("failure")
+
+	err := store.Update(func(tx *Tx) error {
+		if err := tx.DeleteSubject("john"); err != nil {
+			return err
+		}
+		if err := tx.Put("john", "firstName", "Jon"); err != nil {
+			return err
+		}
+
+		triples, err := tx.Query(Subjects("john"))
+		assert.Nil(t, err)
+		assert.Equal(t,
+			[]Triple{{"john", "firstName", "Jon"}},
+			triples,
+		)
+
+		return failure
+	})
+	assert.Equal(t, failure, err)
+
+	triples, err := store.Query(Subjects("john"))
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{{"john", "firstName", "John"}, {"john", "lastName", "Smith"}},
+		triples,
+	)
+}
+
+func TestView(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(Triple{"john", "firstName", "John"})
+
+	err := store.View(func(tx *Tx) error {
+		triples, err := tx.Query(Subjects("john"))
+		assert.Nil(t, err)
+		assert.Equal(t,
+			[]Triple{{"john", "firstName", "John"}},
+			triples,
+		)
+
+		return tx.Put("john", "lastName", "Smith")
+	})
+	assert.Equal(t, bbolt.ErrTxNotWritable, err)
+}
@@ -2,7 +2,14 @@ package no6
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
 )
 
 type Type byte
@@ -13,73 +20,414 @@ const (
 	TypeInt
 	TypeUint
 	TypeFloat
+	TypeTime
+	TypeBytes
+	TypePoint
+	TypeLiteral
+	TypeRef
 )
 
-type Typer struct{}
+var typeNames = map[Type]string{
+	TypeString:  "string",
+	TypeBool:    "bool",
+	TypeInt:     "int",
+	TypeUint:    "uint",
+	TypeFloat:   "float",
+	TypeTime:    "time",
+	TypeBytes:   "bytes",
+	TypePoint:   "point",
+	TypeLiteral: "literal",
+	TypeRef:     "ref",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("type %d", byte(t))
+}
+
+var (
+	// ErrUnsupportedType is returned when storing, or reading, a value of a type
+	// that the Typer does not understand.
+	ErrUnsupportedType = errors.New("no6: unsupported type")
+
+	// ErrTypeMismatch is returned when a stored value is read into a different
+	// type, for example when using a Pred[int] on a predicate that has string
+	// values.
+	ErrTypeMismatch = errors.New("no6: mismatched types")
+)
+
+// minTime and maxTime are the earliest and latest times that can be stored.
+var (
+	minTime = time.Unix(0, math.MinInt64).UTC()
+	maxTime = time.Unix(0, math.MaxInt64).UTC()
+)
+
+// TypeCustom is the first type that can be used by a Codec, those before it are
+// reserved for the types built in to the Typer.
+const TypeCustom Type = 0x80
+
+// A Codec allows the Typer to store values of a Go type it does not understand.
+type Codec interface {
+	// Type is written before each value encoded by the codec. It must be at
+	// least TypeCustom, and not used by another codec.
+	Type() Type
+
+	// Encode writes value as bytes.
+	Encode(value any) ([]byte, error)
+
+	// Decode reads a value written by Encode.
+	Decode(data []byte) (any, error)
+
+	// Compare returns -1 if a < b, 0 if a == b, 1 if a > b, where a and b were
+	// written by Encode. The ordered index kept by the FullTextIndexer compares
+	// the encoded bytes directly, so for values used with it Encode should write
+	// bytes that sort in the same order.
+	Compare(a, b []byte) int
+}
+
+type Typer struct {
+	mu      sync.RWMutex
+	codecs  map[Type]Codec
+	goTypes map[reflect.Type]Codec
+}
+
+// RegisterType sets codec to be used for values with the same Go type as value.
+func (t *Typer) RegisterType(value any, codec Codec) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	goType := reflect.TypeOf(value)
+	typ := codec.Type()
+
+	if builtin(value) {
+		return fmt.Errorf("no6: %v is already understood by the typer", goType)
+	}
+	if typ < TypeCustom {
+		return fmt.Errorf("no6: type %d for %v is reserved", typ, goType)
+	}
+	if _, ok := t.codecs[typ]; ok {
+		return fmt.Errorf("no6: type %d for %v is already registered", typ, goType)
+	}
+	if _, ok := t.goTypes[goType]; ok {
+		return fmt.Errorf("no6: %v is already registered", goType)
+	}
+
+	if t.codecs == nil {
+		t.codecs = map[Type]Codec{}
+		t.goTypes = map[reflect.Type]Codec{}
+	}
+	t.codecs[typ] = codec
+	t.goTypes[goType] = codec
+
+	return nil
+}
+
+// builtin returns true if value is of a type the Typer understands without a
+// Codec.
+func builtin(value any) bool {
+	switch value.(type) {
+	case string, int, uint, bool, float64, time.Time, []byte, Point, Literal, Ref:
+		return true
+	}
+
+	return false
+}
+
+// understands returns true if value can be formatted by the Typer.
+func (t *Typer) understands(value any) bool {
+	if builtin(value) {
+		return true
+	}
+
+	_, ok := t.codecForValue(value)
+	return ok
+}
+
+func (t *Typer) codecForValue(value any) (Codec, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	codec, ok := t.goTypes[reflect.TypeOf(value)]
+	return codec, ok
+}
+
+func (t *Typer) codecForType(typ Type) (Codec, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	codec, ok := t.codecs[typ]
+	return codec, ok
+}
 
-// Format writes val to a byte slice as typ.
-func (t *Typer) Format(val any) []byte {
+// Format writes val to a byte slice as typ. The bytes are written so that
+// comparing two values of the same type with bytes.Compare gives the same
+// result as comparing the values. If val is not a type the Typer understands,
+// or is a time outside the years 1678 to 2262, an error wrapping
+// ErrUnsupportedType is returned.
+func (t *Typer) Format(val any) ([]byte, error) {
 	switch v := val.(type) {
 	case string:
-		return append([]byte{byte(TypeString)}, []byte(v)...)
+		return append([]byte{byte(TypeString)}, []byte(v)...), nil
 	case int:
-		sgn := byte(1)
+		data := make([]byte, 10)
+		data[0] = byte(TypeInt)
+
 		if v < 0 {
-			sgn = byte(0)
-			v = -v
+			// negative numbers with a larger magnitude are smaller, so invert the
+			// bits to keep that order
+			data[1] = 0
+			binary.BigEndian.PutUint64(data[2:], ^uint64(-v))
+		} else {
+			data[1] = 1
+			binary.BigEndian.PutUint64(data[2:], uint64(v))
 		}
 
-		data := make([]byte, 10)
-		data[0] = byte(TypeInt)
-		data[1] = sgn
-		binary.BigEndian.PutUint64(data[2:], uint64(v))
-		return data
+		return data, nil
+	case uint:
+		data := make([]byte, 9)
+		data[0] = byte(TypeUint)
+		binary.BigEndian.PutUint64(data[1:], uint64(v))
+		return data, nil
+	case bool:
+		if v {
+			return []byte{byte(TypeBool), 1}, nil
+		}
+		return []byte{byte(TypeBool), 0}, nil
+	case float64:
+		data := make([]byte, 9)
+		data[0] = byte(TypeFloat)
+		binary.BigEndian.PutUint64(data[1:], floatBits(v))
+		return data, nil
+	case time.Time:
+		// stored as nanoseconds in UTC, so only times between the years 1678
+		// and 2262 can be represented. Flipping the sign bit makes those
+		// before 1970 sort first.
+		if v.Before(minTime) || v.After(maxTime) {
+			return nil, fmt.Errorf("%w: time %v is outside %v to %v", ErrUnsupportedType, v, minTime, maxTime)
+		}
+
+		data := make([]byte, 9)
+		data[0] = byte(TypeTime)
+		binary.BigEndian.PutUint64(data[1:], uint64(v.UnixNano())^(1<<63))
+		return data, nil
+	case []byte:
+		return append([]byte{byte(TypeBytes)}, v...), nil
+	case Point:
+		return formatPoint(v)
+	case Literal:
+		return t.formatLiteral(v)
+	case Ref:
+		return append([]byte{byte(TypeRef)}, v...), nil
 	default:
-		panic("Format only understands some types")
+		codec, ok := t.codecForValue(val)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, val)
+		}
+
+		data, err := codec.Encode(val)
+		if err != nil {
+			return nil, fmt.Errorf("no6: encoding %T: %w", val, err)
+		}
+
+		return append([]byte{byte(codec.Type())}, data...), nil
 	}
 }
 
-// Read parses the value in data as typ.
-func (t *Typer) Read(data []byte) (Type, any) {
+// sizes are the lengths of the types that are always written with the same
+// number of bytes.
+var sizes = map[Type]int{
+	TypeBool:  2,
+	TypeInt:   10,
+	TypeUint:  9,
+	TypeFloat: 9,
+	TypeTime:  9,
+	TypePoint: 25,
+}
+
+// Read parses the value in data as typ. If data is of a type the Typer does not
+// understand an error wrapping ErrUnsupportedType is returned.
+func (t *Typer) Read(data []byte) (Type, any, error) {
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("%w: no data", ErrUnsupportedType)
+	}
+
 	typ := Type(data[0])
+	if size, ok := sizes[typ]; ok && len(data) != size {
+		return typ, nil, fmt.Errorf("no6: value of type %d should be %d bytes, not %d", typ, size, len(data))
+	}
+
 	switch typ {
 	case TypeString:
-		return typ, string(data[1:])
+		return typ, string(data[1:]), nil
 	case TypeInt:
-		num := int(binary.BigEndian.Uint64(data[2:]))
 		if data[1] == 0 {
-			num = -num
+			return typ, -int(^binary.BigEndian.Uint64(data[2:])), nil
+		}
+		return typ, int(binary.BigEndian.Uint64(data[2:])), nil
+	case TypeUint:
+		return typ, uint(binary.BigEndian.Uint64(data[1:])), nil
+	case TypeBool:
+		return typ, data[1] == 1, nil
+	case TypeFloat:
+		bits := binary.BigEndian.Uint64(data[1:])
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
 		}
-		return typ, num
+		return typ, math.Float64frombits(bits), nil
+	case TypeTime:
+		return typ, time.Unix(0, int64(binary.BigEndian.Uint64(data[1:])^(1<<63))).UTC(), nil
+	case TypeBytes:
+		return typ, bytes.Clone(data[1:]), nil
+	case TypePoint:
+		return typ, readPoint(data), nil
+	case TypeLiteral:
+		literal, err := t.readLiteral(data)
+		return typ, literal, err
+	case TypeRef:
+		return typ, Ref(data[1:]), nil
 	default:
-		panic("Read only understands some types")
+		codec, ok := t.codecForType(typ)
+		if !ok {
+			return typ, nil, fmt.Errorf("%w: type %d", ErrUnsupportedType, typ)
+		}
+
+		value, err := codec.Decode(data[1:])
+		if err != nil {
+			return typ, nil, fmt.Errorf("no6: decoding type %d: %w", typ, err)
+		}
+
+		return typ, value, nil
+	}
+}
+
+// floatBits returns the bits of v arranged so that they sort in the same order
+// as the values.
+func floatBits(v float64) uint64 {
+	// -0 is equal to 0 so must be stored the same
+	if v == 0 {
+		v = 0
+	}
+
+	// positive numbers need the sign bit set to sort after negatives, and
+	// negative numbers all bits flipped so larger magnitudes sort first
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		return ^bits
 	}
+	return bits | 1<<63
 }
 
 // Compare returns -1 if a < b, 0 if a == b, 1 if a > b.
-func (t *Typer) Compare(a, b []byte) int {
+//
+// Values of different types can be compared, they are ordered by their Type
+// first and then by value. So all strings are before all bools, which are
+// before numbers, then times, bytes, points, literals, refs and finally any
+// custom types in the order of their Type. Ints, uints and floats are all
+// numbers, so are compared by value with each other: the uint 2 is before the
+// float 3.5, which is before the int 4. Points are ordered along a Z-order
+// curve, and literals by language then datatype then value. This is the same
+// order as the bytes of sortKey, so is also the order of the ordered index.
+func (t *Typer) Compare(a, b []byte) (int, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, fmt.Errorf("%w: no data", ErrUnsupportedType)
+	}
+
+	a, b = sortKey(a), sortKey(b)
 	if a[0] != b[0] {
-		panic("Compare only works on the same types")
+		return cmp.Compare(a[0], b[0]), nil
 	}
 
 	typ := Type(a[0])
 	switch typ {
-	case TypeString:
-		return bytes.Compare(a[1:], b[1:])
+	case TypeString, TypeBool, TypeInt, TypeUint, TypeFloat, TypeTime, TypeBytes, TypePoint, TypeLiteral, TypeRef:
+		return bytes.Compare(a[1:], b[1:]), nil
+	default:
+		codec, ok := t.codecForType(typ)
+		if !ok {
+			return 0, fmt.Errorf("%w: type %d", ErrUnsupportedType, typ)
+		}
+
+		return codec.Compare(a[1:], b[1:]), nil
+	}
+}
+
+// sortKey returns data, as written by Format, in a form where comparing the
+// bytes gives the order described on Compare.
+//
+// For most types this is data itself. Ints, uints and floats are written with
+// their own Type so have to be converted to share an order: they are all given
+// the Type of an int, followed by the value as a float64, followed by the
+// difference between the value and that float64 to order large ints and uints
+// that can't be represented exactly, followed by the original Type so that
+// equal numbers of different types still have different keys.
+func sortKey(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+
+	var (
+		f    float64
+		diff int64
+	)
+
+	switch Type(data[0]) {
 	case TypeInt:
-		// simple case when signs differ
-		if a[1] < b[1] {
-			return -1
-		} else if a[1] > b[1] {
-			return 1
+		if len(data) != 10 {
+			return data
+		}
+
+		var v int
+		if data[1] == 0 {
+			v = -int(^binary.BigEndian.Uint64(data[2:]))
+		} else {
+			v = int(binary.BigEndian.Uint64(data[2:]))
 		}
 
-		if a[1] == 0 {
-			return bytes.Compare(b[2:], a[2:])
+		f = float64(v)
+		if f >= 1<<63 {
+			// rounded up past MaxInt64, so doesn't fit in an int64
+			diff = int64(uint64(v) - 1<<63)
+		} else {
+			diff = int64(v) - int64(f)
+		}
+	case TypeUint:
+		if len(data) != 9 {
+			return data
 		}
 
-		return bytes.Compare(a[2:], b[2:])
+		v := binary.BigEndian.Uint64(data[1:])
+		f = float64(v)
+		if f >= 1<<64 {
+			// rounded up past MaxUint64, so doesn't fit in a uint64
+			diff = int64(v)
+		} else {
+			diff = int64(v - uint64(f))
+		}
+	case TypeFloat:
+		if len(data) != 9 {
+			return data
+		}
+
+		bits := binary.BigEndian.Uint64(data[1:])
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		f = math.Float64frombits(bits)
 	default:
-		panic("Compare only understands some types")
+		return data
 	}
+
+	key := make([]byte, 18)
+	key[0] = byte(TypeInt)
+	binary.BigEndian.PutUint64(key[1:], floatBits(f))
+	binary.BigEndian.PutUint64(key[9:], uint64(diff)^(1<<63))
+	key[17] = data[0]
+	return key
 }
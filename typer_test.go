@@ -1,7 +1,15 @@
 package no6
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"testing"
+	"time"
 
 	"hawx.me/code/assert"
 )
@@ -51,13 +59,340 @@ func TestTyperCompare(t *testing.T) {
 			b:      -1000,
 			result: 1,
 		},
+		"string before int": {
+			a:      "20",
+			b:      20,
+			result: -1,
+		},
+		"bool before int": {
+			a:      true,
+			b:      -1000,
+			result: -1,
+		},
+		"int after smaller uint": {
+			a:      20,
+			b:      uint(1),
+			result: 1,
+		},
+		"float before larger int": {
+			a:      0.5,
+			b:      math.MaxInt,
+			result: -1,
+		},
+		"time after float": {
+			a:      time.Date(1901, 1, 1, 0, 0, 0, 0, time.UTC),
+			b:      math.Inf(1),
+			result: 1,
+		},
+		"bytes after string": {
+			a:      []byte("a"),
+			b:      "b",
+			result: 1,
+		},
 	}
 
 	typer := &Typer{}
 
 	for scenario, tc := range testcases {
 		t.Run(scenario, func(t *testing.T) {
-			assert.Equal(t, tc.result, typer.Compare(typer.Format(tc.a), typer.Format(tc.b)))
+			assert.Equal(t, tc.result, mustCompare(typer, mustFormat(typer, tc.a), mustFormat(typer, tc.b)))
 		})
 	}
 }
+
+func TestTyperCompareNumbers(t *testing.T) {
+	typer := &Typer{}
+
+	values := []any{
+		math.Inf(-1),
+		math.MinInt,
+		-1000.5,
+		-1000,
+		-1,
+		0,
+		uint(0),
+		0.0,
+		uint(2),
+		3.5,
+		4,
+		10.0,
+		math.MaxInt - 1,
+		math.MaxInt,
+		uint(math.MaxInt + 1),
+		float64(math.MaxInt),
+		uint(math.MaxUint - 1),
+		uint(math.MaxUint),
+		float64(math.MaxUint),
+		math.Inf(1),
+	}
+
+	for i := 0; i < len(values)-1; i++ {
+		a, b := mustFormat(typer, values[i]), mustFormat(typer, values[i+1])
+		assert.Equal(t, -1, mustCompare(typer, a, b))
+		assert.Equal(t, 1, mustCompare(typer, b, a))
+		assert.Equal(t, -1, bytes.Compare(sortKey(a), sortKey(b)))
+	}
+}
+
+func TestTyperFormatOrder(t *testing.T) {
+	typer := &Typer{}
+
+	values := []any{math.MinInt, -1000, -999, -1, 0, 1, 999, 1000, math.MaxInt}
+
+	for i := 0; i < len(values)-1; i++ {
+		a, b := mustFormat(typer, values[i]), mustFormat(typer, values[i+1])
+		assert.Equal(t, -1, bytes.Compare(a, b))
+
+		_, read := mustRead(typer, a)
+		assert.Equal(t, values[i], read)
+	}
+}
+
+func TestTyperFormatNegativeZero(t *testing.T) {
+	typer := &Typer{}
+
+	assert.Equal(t, mustFormat(typer, 0.0), mustFormat(typer, math.Copysign(0, -1)))
+	assert.Equal(t, 0, mustCompare(typer, mustFormat(typer, 0.0), mustFormat(typer, math.Copysign(0, -1))))
+
+	_, read := mustRead(typer, mustFormat(typer, math.Copysign(0, -1)))
+	assert.False(t, math.Signbit(read.(float64)))
+}
+
+func TestTyperFormatOrderTypes(t *testing.T) {
+	typer := &Typer{}
+
+	testcases := map[string][]any{
+		"bool":  {false, true},
+		"uint":  {uint(0), uint(1), uint(255), uint(256), uint(math.MaxUint)},
+		"float": {math.Inf(-1), -math.MaxFloat64, -1.5, -1.0, -math.SmallestNonzeroFloat64, 0.0, math.SmallestNonzeroFloat64, 0.25, 1.0, 1e10, math.Inf(1)},
+		"time": {
+			time.Date(1901, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(1969, 12, 31, 23, 59, 59, 999, time.UTC),
+			time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 29, 12, 0, 0, 1, time.UTC),
+		},
+		"bytes": {[]byte{}, []byte{0}, []byte{0, 1}, []byte{1}, []byte{255, 0}},
+	}
+
+	for scenario, values := range testcases {
+		t.Run(scenario, func(t *testing.T) {
+			for i := 0; i < len(values)-1; i++ {
+				a, b := mustFormat(typer, values[i]), mustFormat(typer, values[i+1])
+				assert.Equal(t, -1, mustCompare(typer, a, b))
+				assert.Equal(t, 1, mustCompare(typer, b, a))
+				assert.Equal(t, 0, mustCompare(typer, a, a))
+			}
+
+			for _, value := range values {
+				_, read := mustRead(typer, mustFormat(typer, value))
+				assert.Equal(t, value, read)
+			}
+		})
+	}
+}
+
+func TestTyperReadTimeIsUTC(t *testing.T) {
+	typer := &Typer{}
+
+	local := time.Date(2024, 6, 1, 9, 30, 0, 0, time.FixedZone("BST", 60*60))
+	typ, read := mustRead(typer, mustFormat(typer, local))
+
+	assert.Equal(t, TypeTime, typ)
+	assert.Equal(t, time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC), read)
+}
+
+// durationCodec stores time.Duration like the built in int, with the sign bit
+// flipped so that the bytes sort in order.
+type durationCodec struct{}
+
+func (durationCodec) Type() Type { return TypeCustom }
+
+func (durationCodec) Encode(value any) ([]byte, error) {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(value.(time.Duration))^(1<<63))
+	return data, nil
+}
+
+func (durationCodec) Decode(data []byte) (any, error) {
+	if len(data) != 8 {
+		return nil, errors.New("duration must be 8 bytes")
+	}
+	return time.Duration(binary.BigEndian.Uint64(data) ^ (1 << 63)), nil
+}
+
+func (durationCodec) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// versionCodec stores versions as text, so relies on Compare for ordering.
+type version struct{ Major, Minor int }
+
+type versionCodec struct{}
+
+func (versionCodec) Type() Type { return TypeCustom + 1 }
+
+func (versionCodec) Encode(value any) ([]byte, error) {
+	v := value.(version)
+	return fmt.Appendf(nil, "%d.%d", v.Major, v.Minor), nil
+}
+
+func (versionCodec) Decode(data []byte) (any, error) {
+	var v version
+	_, err := fmt.Sscanf(string(data), "%d.%d", &v.Major, &v.Minor)
+	return v, err
+}
+
+func (c versionCodec) Compare(a, b []byte) int {
+	va, _ := c.Decode(a)
+	vb, _ := c.Decode(b)
+
+	return cmp.Or(
+		cmp.Compare(va.(version).Major, vb.(version).Major),
+		cmp.Compare(va.(version).Minor, vb.(version).Minor),
+	)
+}
+
+func TestTyperRegisterType(t *testing.T) {
+	typer := &Typer{}
+
+	assert.Nil(t, typer.RegisterType(time.Duration(0), durationCodec{}))
+	assert.Nil(t, typer.RegisterType(version{}, versionCodec{}))
+
+	t.Run("round trip", func(t *testing.T) {
+		typ, value := mustRead(typer, mustFormat(typer, -90*time.Second))
+		assert.Equal(t, TypeCustom, typ)
+		assert.Equal(t, -90*time.Second, value)
+
+		typ, value = mustRead(typer, mustFormat(typer, version{1, 10}))
+		assert.Equal(t, TypeCustom+1, typ)
+		assert.Equal(t, version{1, 10}, value)
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		assert.Equal(t, -1, mustCompare(typer, mustFormat(typer, -time.Hour), mustFormat(typer, time.Second)))
+		assert.Equal(t, 1, mustCompare(typer, mustFormat(typer, version{1, 10}), mustFormat(typer, version{1, 9})))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.NotNil(t, typer.RegisterType(0, durationCodec{}))
+		assert.NotNil(t, typer.RegisterType(time.Month(0), durationCodec{}))
+		assert.NotNil(t, typer.RegisterType(time.Duration(0), versionCodec{}))
+		assert.NotNil(t, typer.RegisterType(time.Weekday(0), reservedCodec{}))
+	})
+}
+
+type reservedCodec struct{ durationCodec }
+
+func (reservedCodec) Type() Type { return TypeInt }
+
+func TestStoreRegisterType(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+
+	assert.Nil(t, store.RegisterType(time.Duration(0), durationCodec{}))
+	assert.Nil(t, store.RegisterType(version{}, versionCodec{}))
+	assert.Nil(t, store.SetIndexer("length", FullTextIndexer{}))
+
+	store.PutTriples(
+		Triple{"a", "length", 3 * time.Minute},
+		Triple{"b", "length", 90 * time.Second},
+		Triple{"c", "length", time.Hour},
+		Triple{"a", "version", version{1, 10}},
+		Triple{"b", "version", version{1, 9}},
+		Triple{"c", "version", version{0, 12}},
+	)
+
+	triples, err := store.Query(Subjects("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{
+		{"a", "length", 3 * time.Minute},
+		{"a", "version", version{1, 10}},
+	}, triples)
+
+	subjects, err := store.QuerySubjects(Predicates("length").Lt(5*time.Minute), Sort("length"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "a"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("version").Gt(version{1, 0}), Sort("version").Desc())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, subjects)
+
+	subjects, err = store.QuerySubjects(Predicates("version").Eq(version{0, 12}))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c"}, subjects)
+}
+
+func mustFormat(typer *Typer, value any) []byte {
+	data, err := typer.Format(value)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func mustRead(typer *Typer, data []byte) (Type, any) {
+	typ, value, err := typer.Read(data)
+	if err != nil {
+		panic(err)
+	}
+	return typ, value
+}
+
+func mustCompare(typer *Typer, a, b []byte) int {
+	c, err := typer.Compare(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestTyperErrors(t *testing.T) {
+	typer := &Typer{}
+
+	t.Run("Format unsupported", func(t *testing.T) {
+		for _, value := range []any{int64(1), struct{}{}, nil, []string{"a"}} {
+			_, err := typer.Format(value)
+			assert.True(t, errors.Is(err, ErrUnsupportedType))
+		}
+	})
+
+	t.Run("Format time out of range", func(t *testing.T) {
+		for _, value := range []time.Time{
+			time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC),
+			{},
+			minTime.Add(-time.Nanosecond),
+			maxTime.Add(time.Nanosecond),
+		} {
+			_, err := typer.Format(value)
+			assert.True(t, errors.Is(err, ErrUnsupportedType))
+		}
+
+		for _, value := range []time.Time{minTime, maxTime} {
+			data, err := typer.Format(value)
+			assert.Nil(t, err)
+
+			_, read, err := typer.Read(data)
+			assert.Nil(t, err)
+			assert.True(t, value.Equal(read.(time.Time)))
+		}
+	})
+
+	t.Run("Read unsupported", func(t *testing.T) {
+		_, _, err := typer.Read([]byte{byte(TypeCustom), 1, 2})
+		assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+		_, _, err = typer.Read(nil)
+		assert.True(t, errors.Is(err, ErrUnsupportedType))
+	})
+
+	t.Run("Read malformed", func(t *testing.T) {
+		_, _, err := typer.Read([]byte{byte(TypeInt), 1, 2})
+		assert.NotNil(t, err)
+	})
+}
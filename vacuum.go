@@ -0,0 +1,221 @@
+package no6
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"go.etcd.io/bbolt"
+)
+
+// VacuumOptions configure what Vacuum does.
+type VacuumOptions struct {
+	// Compact rewrites the database file once unused entries have been removed,
+	// so that the space they took is returned to the filesystem. Any
+	// transactions wait until compacting has finished.
+	Compact bool
+}
+
+// VacuumStats reports what was reclaimed by Vacuum.
+type VacuumStats struct {
+	// Values is the number of subjects and objects removed from the dictionary.
+	Values int
+	// Predicates is the number of predicates removed, as they had no values.
+	Predicates int
+
+	// SizeBefore and SizeAfter are the size of the database file in bytes, they
+	// are only set when compacting.
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// Vacuum removes entries that are no longer used by any triple. Deleting only
+// removes posting lists, so the subjects and objects they referred to stay in
+// the dictionary, and predicates stay listed even once they have no values.
+func (s *Store) Vacuum(opts VacuumOptions) (VacuumStats, error) {
+	var stats VacuumStats
+
+	err := s.update(func(tx *bbolt.Tx) error {
+		predicatesBucket := tx.Bucket(bucketPredicates)
+		if predicatesBucket == nil {
+			return nil
+		}
+
+		// first find every UID that is still in use, and the predicates that are
+		// not
+		referenced := map[uint64]struct{}{}
+		var emptyPredicates [][]byte
+
+		if err := predicatesBucket.ForEach(func(p, _ []byte) error {
+			predicateBucket := tx.Bucket([]byte("predicate-" + string(p)))
+			if predicateBucket == nil {
+				emptyPredicates = append(emptyPredicates, p)
+				return nil
+			}
+
+			if k, _ := predicateBucket.Cursor().First(); k == nil {
+				emptyPredicates = append(emptyPredicates, p)
+				return nil
+			}
+
+			return predicateBucket.ForEach(func(k, v []byte) error {
+				referenced[keySubject(k)] = struct{}{}
+				for i := 0; i < len(v); i += 8 {
+					referenced[readUID(v[i:i+8])] = struct{}{}
+				}
+				return nil
+			})
+		}); err != nil {
+			return err
+		}
+
+		for _, p := range emptyPredicates {
+			for _, name := range [][]byte{
+				[]byte("predicate-" + string(p)),
+				reverseBucketName(string(p)),
+				geoBucketName(string(p)),
+			} {
+				if tx.Bucket(name) != nil {
+					if err := tx.DeleteBucket(name); err != nil {
+						return err
+					}
+				}
+			}
+			if err := predicatesBucket.Delete(p); err != nil {
+				return err
+			}
+
+			s.logger.Debug("DELETE",
+				slog.String("bucket", string(bucketPredicates)),
+				slog.String("key", string(p)))
+			stats.Predicates++
+		}
+
+		// then anything in the dictionary not referenced can go
+		dict, err := s.writeDictionary(tx)
+		if err != nil {
+			return err
+		}
+
+		var unused [][]byte
+		if err := dict.values.ForEach(func(uid, _ []byte) error {
+			if _, ok := referenced[readUID(uid)]; !ok {
+				unused = append(unused, uid)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, uid := range unused {
+			if err := dict.remove(uid); err != nil {
+				return err
+			}
+
+			s.logger.Debug("DELETE",
+				slog.String("bucket", string(bucketValues)),
+				slog.Uint64("uid", readUID(uid)))
+		}
+		stats.Values = len(unused)
+
+		return nil
+	})
+	if err != nil || !opts.Compact {
+		return stats, err
+	}
+
+	stats.SizeBefore, stats.SizeAfter, err = s.compact()
+	return stats, err
+}
+
+// compact copies the database to a new file, then replaces the original with
+// it. It returns the size of the file before and after.
+//
+// Transactions wait for compact to finish. If it fails the Store is left using
+// the original file, unless that can no longer be opened.
+func (s *Store) compact() (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.db.Path()
+	compactPath := path + ".compact"
+	originalPath := path + ".original"
+
+	before, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// a compaction that crashed may have left files behind, which must not be
+	// reused
+	for _, stale := range []string{compactPath, originalPath} {
+		if err := os.Remove(stale); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, 0, err
+		}
+	}
+
+	if err := s.compactTo(compactPath); err != nil {
+		os.Remove(compactPath)
+		return 0, 0, err
+	}
+
+	// keep a link to the original, so that it can be put back if the compacted
+	// file fails to open; the file at path is always one or the other
+	if err := os.Link(path, originalPath); err != nil {
+		os.Remove(compactPath)
+		return 0, 0, err
+	}
+	defer os.Remove(originalPath)
+
+	if err := s.db.Close(); err != nil {
+		os.Remove(compactPath)
+		return 0, 0, err
+	}
+
+	if err := os.Rename(compactPath, path); err != nil {
+		os.Remove(compactPath)
+		return 0, 0, errors.Join(err, s.reopen(path))
+	}
+
+	if err := s.reopen(path); err != nil {
+		if restoreErr := os.Rename(originalPath, path); restoreErr != nil {
+			return 0, 0, errors.Join(err, restoreErr)
+		}
+
+		return 0, 0, errors.Join(err, s.reopen(path))
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return before.Size(), after.Size(), nil
+}
+
+// compactTo writes a compacted copy of the database to a new file at path.
+func (s *Store) compactTo(path string) error {
+	dst, err := bbolt.Open(path, s.fileMode, s.options)
+	if err != nil {
+		return err
+	}
+
+	if err := bbolt.Compact(dst, s.db, 0); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// reopen opens the database file at path for the Store to use.
+func (s *Store) reopen(path string) error {
+	db, err := bbolt.Open(path, s.fileMode, s.options)
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+	return nil
+}
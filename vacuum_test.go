@@ -0,0 +1,165 @@
+package no6
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"hawx.me/code/assert"
+)
+
+func TestVacuum(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	store.PutTriples(
+		Triple{"john", "firstName", "John"},
+		Triple{"john", "lastName", "Smith"},
+		Triple{"john", "knows", "dave"},
+		Triple{"dave", "firstName", "Dave"},
+		Triple{"dave", "lastName", "Smith"},
+	)
+
+	store.DeleteSubject("john")
+
+	stats, err := store.Vacuum(VacuumOptions{})
+	assert.Nil(t, err)
+	// john, "John", "dave"
+	assert.Equal(t, 3, stats.Values)
+	// knows
+	assert.Equal(t, 1, stats.Predicates)
+
+	triples, err := store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]Triple{
+			{"dave", "firstName", "Dave"},
+			{"dave", "lastName", "Smith"},
+		},
+		triples,
+	)
+
+	store.db.View(func(tx *bbolt.Tx) error {
+		assert.Nil(t, tx.Bucket([]byte("predicate-knows")))
+		assert.Nil(t, tx.Bucket(reverseBucketName("knows")))
+		assert.Nil(t, tx.Bucket(bucketPredicates).Get([]byte("knows")))
+		assert.Nil(t, tx.Bucket(bucketNodes).Get([]byte("john")))
+		assert.Equal(t, 1, tx.Bucket(bucketNodes).Stats().KeyN)
+		assert.Equal(t, 2, tx.Bucket(bucketLiterals).Stats().KeyN)
+		assert.Equal(t, 3, tx.Bucket(bucketValues).Stats().KeyN)
+		return nil
+	})
+
+	stats, err = store.Vacuum(VacuumOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, VacuumStats{}, stats)
+}
+
+func TestVacuumCompact(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+
+	var triples []Triple
+	for i := 0; i < 1000; i++ {
+		triples = append(triples, Triple{"john", "count", i})
+	}
+	store.PutTriples(triples...)
+	store.PutTriples(Triple{"dave", "firstName", "Dave"})
+	store.DeleteSubject("john")
+
+	stats, err := store.Vacuum(VacuumOptions{Compact: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1001, stats.Values)
+	assert.True(t, stats.SizeAfter < stats.SizeBefore)
+
+	triples, err = store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"dave", "firstName", "Dave"}}, triples)
+	assert.Nil(t, store.Put("dave", "lastName", "Davidson"))
+	assert.Nil(t, store.Close())
+}
+
+func TestVacuumCompactStale(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+	store.PutTriples(Triple{"dave", "firstName", "Dave"})
+
+	// a file left by a crashed compaction is replaced, not reused
+	assert.Nil(t, os.WriteFile(file.Name()+".compact", []byte("not a database"), 0600))
+
+	_, err := store.Vacuum(VacuumOptions{Compact: true})
+	assert.Nil(t, err)
+
+	_, err = os.Stat(file.Name() + ".compact")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	triples, err := store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"dave", "firstName", "Dave"}}, triples)
+}
+
+func TestVacuumCompactFailure(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+	store.PutTriples(Triple{"dave", "firstName", "Dave"})
+
+	// a directory can't be removed, or opened as a database
+	assert.Nil(t, os.MkdirAll(filepath.Join(file.Name()+".compact", "dir"), 0700))
+	defer os.RemoveAll(file.Name() + ".compact")
+
+	_, err := store.Vacuum(VacuumOptions{Compact: true})
+	assert.NotNil(t, err)
+
+	triples, err := store.Query()
+	assert.Nil(t, err)
+	assert.Equal(t, []Triple{{"dave", "firstName", "Dave"}}, triples)
+	assert.Nil(t, store.Put("dave", "lastName", "Davidson"))
+}
+
+func TestVacuumCompactConcurrent(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	defer store.Close()
+	store.PutTriples(Triple{"dave", "firstName", "Dave"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := store.Query(Subjects("dave")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := store.Vacuum(VacuumOptions{Compact: true})
+		assert.Nil(t, err)
+	}
+	wg.Wait()
+}
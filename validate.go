@@ -0,0 +1,310 @@
+package no6
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// The shapes bucket contains (type, JSON(shape)) pairs, giving the shape that
+// subjects with the type as a value of the "type" predicate must fit. Its
+// sequence is incremented whenever a shape changes, so that decoded shapes can
+// be cached until then.
+var bucketShapes = []byte("shapes")
+
+// shapePredicate is the predicate linking subjects to the shapes they must fit.
+const shapePredicate = "type"
+
+// ErrInvalid is wrapped by each Violation, so that errors.Is can tell when a
+// write was rejected for not fitting a shape.
+var ErrInvalid = errors.New("no6: subject does not fit its shape")
+
+// A Shape describes the values that subjects of a type must have, by predicate.
+// Predicates that are not in the shape may have any values.
+//
+//	Shape{
+//		"published": {Min: 1, Max: 1, Types: []Type{TypeTime}},
+//		"name":      {Max: 1},
+//	}
+type Shape map[string]Property
+
+// A Property describes the values a subject must have for a predicate.
+type Property struct {
+	// Min is the fewest values the predicate must have.
+	Min int
+	// Max is the most values the predicate may have, there is no limit if it is
+	// zero.
+	Max int
+	// Types, if given, are the only types the values may be.
+	Types []Type
+}
+
+// A Violation is a way in which a subject does not fit the shape for one of its
+// types.
+type Violation struct {
+	Subject   string
+	Type      string
+	Predicate string
+	Reason    string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("no6: %s %q %s", v.Type, v.Subject, v.Reason)
+}
+
+func (v Violation) Unwrap() error {
+	return ErrInvalid
+}
+
+// SetShape sets the shape that subjects must fit when they have typ as a value
+// of the "type" predicate. It is checked whenever a subject is written, with the
+// write failing if the subject does not fit, but existing subjects are not
+// checked; use Validate to find those that do not fit.
+//
+// Subjects are checked once each transaction has finished, so if the shape
+// has a predicate with a Min a subject must be given its type and those values
+// together, with PutTriples, PutStruct or Update. Put writes one triple in its
+// own transaction, so can't be used to give a subject the type.
+func (s *Store) SetShape(typ string, shape Shape) error {
+	data, err := json.Marshal(shape)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bbolt.Tx) error {
+		shapesBucket, err := tx.CreateBucketIfNotExists(bucketShapes)
+		if err != nil {
+			return err
+		}
+		if _, err := shapesBucket.NextSequence(); err != nil {
+			return err
+		}
+
+		return shapesBucket.Put([]byte(typ), data)
+	})
+}
+
+// RemoveShape stops subjects with typ from being checked.
+func (s *Store) RemoveShape(typ string) error {
+	return s.update(func(tx *bbolt.Tx) error {
+		shapesBucket := tx.Bucket(bucketShapes)
+		if shapesBucket == nil {
+			return nil
+		}
+		if _, err := shapesBucket.NextSequence(); err != nil {
+			return err
+		}
+
+		return shapesBucket.Delete([]byte(typ))
+	})
+}
+
+// shapeCache holds the shapes last decoded, and the sequence of the shapes
+// bucket they were decoded at.
+type shapeCache struct {
+	mu       sync.Mutex
+	loaded   bool
+	sequence uint64
+	shapes   map[string]Shape
+}
+
+// readShapes returns the shapes in the store by type, or nil if there are none.
+// They are only decoded again when the shapes bucket has changed, so must not
+// be modified.
+func (s *Store) readShapes(tx *bbolt.Tx) (map[string]Shape, error) {
+	shapesBucket := tx.Bucket(bucketShapes)
+	if shapesBucket == nil {
+		return nil, nil
+	}
+
+	s.shapes.mu.Lock()
+	defer s.shapes.mu.Unlock()
+
+	if s.shapes.loaded && s.shapes.sequence == shapesBucket.Sequence() {
+		return s.shapes.shapes, nil
+	}
+
+	shapes, err := decodeShapes(shapesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	s.shapes.loaded = true
+	s.shapes.sequence = shapesBucket.Sequence()
+	s.shapes.shapes = shapes
+	return shapes, nil
+}
+
+// decodeShapes returns the shapes in shapesBucket by type, or nil if there are
+// none.
+func decodeShapes(shapesBucket *bbolt.Bucket) (map[string]Shape, error) {
+	var shapes map[string]Shape
+	err := shapesBucket.ForEach(func(k, v []byte) error {
+		var shape Shape
+		if err := json.Unmarshal(v, &shape); err != nil {
+			return fmt.Errorf("no6: reading shape %q: %w", k, err)
+		}
+
+		if shapes == nil {
+			shapes = map[string]Shape{}
+		}
+		shapes[string(k)] = shape
+		return nil
+	})
+
+	return shapes, err
+}
+
+// Validate returns every way that the subjects in the store do not fit the
+// shapes for their types.
+func (s *Store) Validate() ([]Violation, error) {
+	var violations []Violation
+
+	err := s.view(func(tx *bbolt.Tx) error {
+		shapes, err := s.readShapes(tx)
+		if err != nil || shapes == nil {
+			return err
+		}
+
+		typeBucket := tx.Bucket([]byte("predicate-" + shapePredicate))
+		if typeBucket == nil {
+			return nil
+		}
+
+		dict := s.readDictionary(tx)
+		return typeBucket.ForEach(func(k, _ []byte) error {
+			violations = append(violations, checkShapes(tx, dict, shapes, keySubject(k))...)
+			return nil
+		})
+	})
+
+	return violations, err
+}
+
+// validate checks that each of subjects fits the shapes for its types,
+// returning the violations joined as an error.
+func (s *Store) validate(tx *bbolt.Tx, subjects map[string]struct{}) error {
+	typeBucket := tx.Bucket([]byte("predicate-" + shapePredicate))
+	if len(subjects) == 0 || typeBucket == nil {
+		return nil
+	}
+
+	dict := s.readDictionary(tx)
+
+	// only subjects with a type can have a shape to fit, so there is no need
+	// to read the shapes if none do
+	var typed []uint64
+	for subject := range subjects {
+		subjectUID := dict.nodeUID(subject)
+		if subjectUID == nil {
+			continue
+		}
+
+		if typeBucket.Get(makeKey(readUID(subjectUID), shapePredicate)) != nil {
+			typed = append(typed, readUID(subjectUID))
+		}
+	}
+	if len(typed) == 0 {
+		return nil
+	}
+
+	shapes, err := s.readShapes(tx)
+	if err != nil || shapes == nil {
+		return err
+	}
+
+	slices.Sort(typed)
+
+	var errs []error
+	for _, subjectUID := range typed {
+		for _, violation := range checkShapes(tx, dict, shapes, subjectUID) {
+			errs = append(errs, violation)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkShapes returns the ways in which subjectUID does not fit the shapes for
+// its types.
+func checkShapes(tx *bbolt.Tx, dict *dictionary, shapes map[string]Shape, subjectUID uint64) []Violation {
+	typeBucket := tx.Bucket([]byte("predicate-" + shapePredicate))
+	if typeBucket == nil {
+		return nil
+	}
+
+	subject, _ := dict.node(writeUID(subjectUID))
+	typeList := typeBucket.Get(makeKey(subjectUID, shapePredicate))
+
+	var violations []Violation
+	for i := 0; i < len(typeList); i += 8 {
+		typ, ok := textOf(dict.literal(typeList[i : i+8]))
+		if !ok {
+			continue
+		}
+		shape, ok := shapes[typ]
+		if !ok {
+			continue
+		}
+
+		predicates := make([]string, 0, len(shape))
+		for predicate := range shape {
+			predicates = append(predicates, predicate)
+		}
+		sort.Strings(predicates)
+
+		for _, predicate := range predicates {
+			var postingList []byte
+			if predicateBucket := tx.Bucket([]byte("predicate-" + predicate)); predicateBucket != nil {
+				postingList = predicateBucket.Get(makeKey(subjectUID, predicate))
+			}
+
+			for _, reason := range shape[predicate].check(dict, predicate, postingList) {
+				violations = append(violations, Violation{
+					Subject:   subject,
+					Type:      typ,
+					Predicate: predicate,
+					Reason:    reason,
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+// check returns the reasons that the values in postingList do not fit p.
+func (p Property) check(dict *dictionary, predicate string, postingList []byte) []string {
+	var reasons []string
+
+	count := len(postingList) / 8
+	switch {
+	case p.Max > 0 && p.Min == p.Max && count != p.Min:
+		reasons = append(reasons, fmt.Sprintf("must have exactly %d %q, has %d", p.Min, predicate, count))
+	case count < p.Min:
+		reasons = append(reasons, fmt.Sprintf("must have at least %d %q, has %d", p.Min, predicate, count))
+	case p.Max > 0 && count > p.Max:
+		reasons = append(reasons, fmt.Sprintf("must have at most %d %q, has %d", p.Max, predicate, count))
+	}
+
+	if len(p.Types) > 0 {
+		for i := 0; i < len(postingList); i += 8 {
+			data := dict.literal(postingList[i : i+8])
+			if len(data) == 0 {
+				continue
+			}
+
+			if typ := Type(data[0]); !slices.Contains(p.Types, typ) {
+				reasons = append(reasons, fmt.Sprintf("must have %q of %v, not %v", predicate, p.Types, typ))
+				break
+			}
+		}
+	}
+
+	return reasons
+}
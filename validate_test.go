@@ -0,0 +1,170 @@
+package no6
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"hawx.me/code/assert"
+)
+
+func TestShapes(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err := store.SetShape("h-entry", Shape{
+		"published": {Min: 1, Max: 1, Types: []Type{TypeTime}},
+		"name":      {Max: 1},
+	})
+	assert.Nil(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		err := store.PutTriples(
+			Triple{"a", "type", "h-entry"},
+			Triple{"a", "published", published},
+			Triple{"a", "name", "Hello"},
+		)
+		assert.Nil(t, err)
+
+		results, _ := store.Query(Subjects("a"))
+		assert.Len(t, results, 3)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		err := store.PutTriples(
+			Triple{"b", "type", "h-entry"},
+			Triple{"b", "name", "Hello"},
+		)
+		assert.True(t, errors.Is(err, ErrInvalid))
+
+		var violation Violation
+		assert.True(t, errors.As(err, &violation))
+		assert.Equal(t, Violation{
+			Subject:   "b",
+			Type:      "h-entry",
+			Predicate: "published",
+			Reason:    `must have exactly 1 "published", has 0`,
+		}, violation)
+
+		results, _ := store.Query(Subjects("b"))
+		assert.Len(t, results, 0)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		err := store.Put("a", "name", "Goodbye")
+		assert.True(t, errors.Is(err, ErrInvalid))
+
+		results, _ := store.Query(Subjects("a"), Predicates("name"))
+		assert.Len(t, results, 1)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		err := store.PutTriples(
+			Triple{"c", "type", "h-entry"},
+			Triple{"c", "published", "yesterday"},
+		)
+		assert.True(t, errors.Is(err, ErrInvalid))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := store.Delete("a", "published")
+		assert.True(t, errors.Is(err, ErrInvalid))
+
+		err = store.DeleteSubject("a")
+		assert.Nil(t, err)
+	})
+
+	t.Run("untyped", func(t *testing.T) {
+		err := store.PutTriples(
+			Triple{"d", "name", "One"},
+			Triple{"d", "name", "Two"},
+		)
+		assert.Nil(t, err)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		store.PutTriples(
+			Triple{"e", "type", "h-card"},
+			Triple{"e", "name", "Alice"},
+		)
+
+		violations, err := store.Validate()
+		assert.Nil(t, err)
+		assert.Len(t, violations, 0)
+
+		store.SetShape("h-card", Shape{"url": {Min: 1}})
+
+		violations, err = store.Validate()
+		assert.Nil(t, err)
+		assert.Equal(t, []Violation{{
+			Subject:   "e",
+			Type:      "h-card",
+			Predicate: "url",
+			Reason:    `must have at least 1 "url", has 0`,
+		}}, violations)
+
+		store.RemoveShape("h-card")
+
+		violations, err = store.Validate()
+		assert.Nil(t, err)
+		assert.Len(t, violations, 0)
+	})
+}
+
+func TestShapesCache(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	assert.Nil(t, store.SetShape("h-entry", Shape{"name": {Max: 1}}))
+
+	// shapes are not read for subjects without a type
+	assert.Nil(t, store.Put("a", "name", "A"))
+	assert.False(t, store.shapes.loaded)
+
+	assert.Nil(t, store.PutTriples(Triple{"b", "type", "h-entry"}, Triple{"b", "name", "B"}))
+	assert.True(t, store.shapes.loaded)
+
+	// changing a shape is seen by the next write
+	assert.Nil(t, store.SetShape("h-entry", Shape{"name": {Max: 1}, "url": {Min: 1}}))
+
+	err := store.PutTriples(Triple{"c", "type", "h-entry"}, Triple{"c", "name", "C"})
+	assert.True(t, errors.Is(err, ErrInvalid))
+
+	assert.Nil(t, store.RemoveShape("h-entry"))
+	assert.Nil(t, store.PutTriples(Triple{"c", "type", "h-entry"}, Triple{"c", "name", "C"}))
+}
+
+func TestShapesPut(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name())
+	assert.Nil(t, store.SetShape("h-entry", Shape{"content": {Min: 1}}))
+
+	// a lone Put of the type can never fit, as the content isn't written yet
+	err := store.Put("a", "type", "h-entry")
+	assert.True(t, errors.Is(err, ErrInvalid))
+
+	assert.Nil(t, store.PutTriples(
+		Triple{"a", "type", "h-entry"},
+		Triple{"a", "content", "Hello"},
+	))
+
+	assert.Nil(t, store.Update(func(tx *Tx) error {
+		if err := tx.Put("b", "type", "h-entry"); err != nil {
+			return err
+		}
+		return tx.Put("b", "content", "Hello")
+	}))
+
+	// once it has a type other values can be Put
+	assert.Nil(t, store.Put("a", "content", "Goodbye"))
+}
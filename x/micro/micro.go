@@ -7,6 +7,17 @@ import (
 	"hawx.me/code/no6"
 )
 
+// typePredicate gives the types of an object, such as "h-entry".
+var typePredicate = no6.Pred[string]("type")
+
+// refProperties are the properties that can be given the uid of an existing
+// object as a string, such as an h-card for the author or location of a post
+// in a Micropub request.
+var refProperties = map[string]bool{
+	"author":   true,
+	"location": true,
+}
+
 type Store struct {
 	inner      *no6.Store
 	newSubject func(string) string
@@ -21,6 +32,11 @@ func Open(path string, newSubject func(string) string) (*Store, error) {
 	return &Store{inner: store, newSubject: newSubject}, err
 }
 
+// Close releases the underlying database.
+func (s *Store) Close() error {
+	return s.inner.Close()
+}
+
 // Insert adds triples for each item in a typical microformat object, e.g.
 //
 //	{
@@ -28,8 +44,25 @@ func Open(path string, newSubject func(string) string) (*Store, error) {
 //		"properties": { ... }
 //	}
 //
+// Property values may be given as a []string, a []no6.Ref of existing objects,
+// a []map[string]any of nested objects, or a []any of a mix of them. Only
+// references and nested objects are nested when read. A string is read as a
+// string, unless it is given for "author" or "location" and is the uid of an
+// existing object, in which case it is stored as a reference.
+//
 // The subject is returned, or an error if there was a problem.
 func (s *Store) Insert(data map[string]any) (string, error) {
+	var uid string
+
+	err := s.inner.Update(func(tx *no6.Tx) (err error) {
+		uid, err = s.insert(tx, data)
+		return err
+	})
+
+	return uid, err
+}
+
+func (s *Store) insert(tx *no6.Tx, data map[string]any) (string, error) {
 	typ, ok := data["type"].([]string)
 	if !ok || len(typ) != 1 {
 		return "", errors.New("data must include a single string 'type' (I think)")
@@ -37,14 +70,14 @@ func (s *Store) Insert(data map[string]any) (string, error) {
 
 	uid := s.newSubject(typ[0])
 
-	if err := s.setForUID(uid, data); err != nil {
+	if err := s.setForUID(tx, uid, data); err != nil {
 		return "", err
 	}
 
 	return uid, nil
 }
 
-func (s *Store) setForUID(uid string, data map[string]any) error {
+func (s *Store) setForUID(tx *no6.Tx, uid string, data map[string]any) error {
 	typ, ok := data["type"].([]string)
 	if !ok || len(typ) != 1 {
 		return errors.New("data must include a single string 'type' (I think)")
@@ -59,53 +92,98 @@ func (s *Store) setForUID(uid string, data map[string]any) error {
 	triples = append(triples, no6.Triple{Subject: uid, Predicate: "type", Object: typ[0]})
 
 	for k, v := range props {
+		var values []any
 		switch vv := v.(type) {
 		case []string:
 			for _, vvv := range vv {
-				triples = append(triples, no6.Triple{Subject: uid, Predicate: k, Object: vvv})
+				values = append(values, vvv)
+			}
+		case []no6.Ref:
+			for _, vvv := range vv {
+				values = append(values, vvv)
 			}
 		case []map[string]any:
 			for _, vvv := range vv {
-				vuid, err := s.Insert(vvv)
-				if err != nil {
-					return err
-				}
-				triples = append(triples, no6.Triple{Subject: uid, Predicate: k, Object: vuid})
+				values = append(values, vvv)
 			}
+		case []any:
+			values = vv
 		default:
 			return errors.New("invalid properties")
 		}
+
+		for _, value := range values {
+			object, err := s.objectFor(tx, k, value)
+			if err != nil {
+				return err
+			}
+			triples = append(triples, no6.Triple{Subject: uid, Predicate: k, Object: object})
+		}
 	}
 
-	s.inner.PutTriples(triples...)
-	return nil
+	return tx.PutTriples(triples...)
+}
+
+// objectFor returns the object to store for a value of property. Nested
+// objects are inserted and referred to, as are the subjects given as a no6.Ref
+// or as a string for one of refProperties, so that they are nested when read.
+// Other strings are stored as they are.
+func (s *Store) objectFor(tx *no6.Tx, property string, value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if refProperties[property] {
+			if types, _ := typePredicate.Get(tx, v); len(types) > 0 {
+				return no6.Ref(v), nil
+			}
+		}
+		return v, nil
+	case no6.Ref:
+		return v, nil
+	case map[string]any:
+		vuid, err := s.insert(tx, v)
+		if err != nil {
+			return nil, err
+		}
+		return no6.Ref(vuid), nil
+	}
+
+	return nil, errors.New("invalid properties")
 }
 
 // Find retrieves a single microformat object using the query. It will resolve any
 // nested objects also in the database, but not any remote references.
-func (s *Store) Find(predicates []string, qs ...no6.SubjectMatcher) (map[string]any, bool) {
-	subjects := s.inner.QuerySubjects(qs...)
-	if len(subjects) == 0 {
-		return nil, false
-	}
+func (s *Store) Find(predicates []string, qs ...no6.SubjectMatcher) (found map[string]any, ok bool) {
+	s.inner.View(func(tx *no6.Tx) error {
+		subjects, err := tx.QuerySubjects(qs...)
+		if err != nil || len(subjects) == 0 {
+			return err
+		}
 
-	return s.tryResolve(subjects[0], predicates)
+		found, ok = s.tryResolve(tx, subjects[0], predicates)
+		return nil
+	})
+
+	return found, ok
 }
 
 // FindAll retrieves all matching microformat objects. It resolves any nested
-// objects also in the database, but not any remote references.
-func (s *Store) FindAll(predicates []string, qs ...no6.SubjectMatcher) []map[string]any {
-	subjects := s.inner.QuerySubjects(qs...)
-	if len(subjects) == 0 {
-		return nil
-	}
+// objects also in the database, but not any remote references. The objects are
+// all read in the same transaction as the query.
+func (s *Store) FindAll(predicates []string, qs ...no6.SubjectMatcher) (resolved []map[string]any) {
+	s.inner.View(func(tx *no6.Tx) error {
+		subjects, err := tx.QuerySubjects(qs...)
+		if err != nil {
+			return err
+		}
 
-	var resolved []map[string]any
-	for _, subject := range subjects {
-		if v, ok := s.tryResolve(subject, predicates); ok {
-			resolved = append(resolved, v)
+		for _, subject := range subjects {
+			if v, ok := s.tryResolve(tx, subject, predicates); ok {
+				resolved = append(resolved, v)
+			}
 		}
-	}
+
+		return nil
+	})
 
 	return resolved
 }
@@ -114,41 +192,65 @@ func (s *Store) DeleteByUID(uid string) error {
 	return s.inner.DeleteSubject(uid)
 }
 
+// Replace deletes the existing triples for uid and writes data in its place.
+// Both happen in a single transaction, so if data cannot be written the
+// original is kept.
 func (s *Store) Replace(uid string, data map[string]any) error {
-	if err := s.DeleteByUID(uid); err != nil {
-		return err
-	}
+	return s.inner.Update(func(tx *no6.Tx) error {
+		if err := tx.DeleteSubject(uid); err != nil {
+			return err
+		}
 
-	return s.setForUID(uid, data)
+		return s.setForUID(tx, uid, data)
+	})
 }
 
-func (s *Store) Get(uid string) (map[string]any, bool) {
-	return s.tryResolveAll(uid)
+func (s *Store) Get(uid string) (found map[string]any, ok bool) {
+	s.inner.View(func(tx *no6.Tx) error {
+		found, ok = s.tryResolveAll(tx, uid)
+		return nil
+	})
+
+	return found, ok
 }
 
-func (s *Store) One(match ...no6.SubjectMatcher) (map[string]any, bool) {
-	subjects := s.inner.QuerySubjects(match...)
-	if len(subjects) != 1 {
-		return nil, false
-	}
+func (s *Store) One(match ...no6.SubjectMatcher) (found map[string]any, ok bool) {
+	s.inner.View(func(tx *no6.Tx) error {
+		subjects, err := tx.QuerySubjects(match...)
+		if err != nil || len(subjects) != 1 {
+			return err
+		}
+
+		found, ok = s.tryResolveAll(tx, subjects[0])
+		return nil
+	})
 
-	return s.tryResolveAll(subjects[0])
+	return found, ok
 }
 
-func (s *Store) All(match ...no6.SubjectMatcher) []map[string]any {
-	subjects := s.inner.QuerySubjects(match...)
-	result := make([]map[string]any, len(subjects))
+// All retrieves all matching microformat objects, resolving any nested objects.
+// The objects are all read in the same transaction as the query.
+func (s *Store) All(match ...no6.SubjectMatcher) (result []map[string]any) {
+	s.inner.View(func(tx *no6.Tx) error {
+		subjects, err := tx.QuerySubjects(match...)
+		if err != nil {
+			return err
+		}
+
+		result = make([]map[string]any, len(subjects))
+		for i, subject := range subjects {
+			result[i], _ = s.tryResolveAll(tx, subject)
+		}
 
-	for i, subject := range subjects {
-		result[i], _ = s.tryResolveAll(subject)
-	}
+		return nil
+	})
 
 	return result
 }
 
-func (s *Store) tryResolve(id string, predicates []string) (map[string]any, bool) {
-	triples := s.inner.Query(no6.Subjects(id), no6.Predicates(predicates...))
-	if len(triples) == 0 {
+func (s *Store) tryResolve(tx *no6.Tx, id string, predicates []string) (map[string]any, bool) {
+	triples, err := tx.Query(no6.Subjects(id), no6.Predicates(predicates...))
+	if err != nil || len(triples) == 0 {
 		return nil, false
 	}
 
@@ -159,24 +261,21 @@ func (s *Store) tryResolve(id string, predicates []string) (map[string]any, bool
 
 	for _, triple := range triples {
 		if triple.Predicate == "type" {
-			typ = append(typ, triple.Object.(string))
-		} else {
-			if found, ok := props[triple.Predicate]; ok {
-				switch v := found.(type) {
-				case []string:
-					props[triple.Predicate] = append(v, triple.Object.(string))
-				case []map[string]any:
-					if resolved, ok := s.tryResolve(triple.Object.(string), predicates); ok {
-						props[triple.Predicate] = append(v, resolved)
-					}
-				}
+			if object, ok := typePredicate.Object(triple); ok {
+				typ = append(typ, object)
+			}
+			continue
+		}
+
+		switch object := triple.Object.(type) {
+		case no6.Ref:
+			if resolved, ok := s.tryResolve(tx, string(object), predicates); ok {
+				appendProperty(props, triple.Predicate, resolved)
 			} else {
-				if resolved, ok := s.tryResolve(triple.Object.(string), predicates); ok {
-					props[triple.Predicate] = []map[string]any{resolved}
-				} else {
-					props[triple.Predicate] = []string{triple.Object.(string)}
-				}
+				appendProperty(props, triple.Predicate, string(object))
 			}
+		case string:
+			appendProperty(props, triple.Predicate, object)
 		}
 	}
 
@@ -186,9 +285,9 @@ func (s *Store) tryResolve(id string, predicates []string) (map[string]any, bool
 	}, true
 }
 
-func (s *Store) tryResolveAll(id string) (map[string]any, bool) {
-	triples := s.inner.Query(no6.Subjects(id))
-	if len(triples) == 0 {
+func (s *Store) tryResolveAll(tx *no6.Tx, id string) (map[string]any, bool) {
+	triples, err := tx.Query(no6.Subjects(id))
+	if err != nil || len(triples) == 0 {
 		return nil, false
 	}
 
@@ -199,24 +298,21 @@ func (s *Store) tryResolveAll(id string) (map[string]any, bool) {
 
 	for _, triple := range triples {
 		if triple.Predicate == "type" {
-			typ = append(typ, triple.Object.(string))
-		} else {
-			if found, ok := props[triple.Predicate]; ok {
-				switch v := found.(type) {
-				case []string:
-					props[triple.Predicate] = append(v, triple.Object.(string))
-				case []map[string]any:
-					if resolved, ok := s.tryResolveAll(triple.Object.(string)); ok {
-						props[triple.Predicate] = append(v, resolved)
-					}
-				}
+			if object, ok := typePredicate.Object(triple); ok {
+				typ = append(typ, object)
+			}
+			continue
+		}
+
+		switch object := triple.Object.(type) {
+		case no6.Ref:
+			if resolved, ok := s.tryResolveAll(tx, string(object)); ok {
+				appendProperty(props, triple.Predicate, resolved)
 			} else {
-				if resolved, ok := s.tryResolveAll(triple.Object.(string)); ok {
-					props[triple.Predicate] = []map[string]any{resolved}
-				} else {
-					props[triple.Predicate] = []string{triple.Object.(string)}
-				}
+				appendProperty(props, triple.Predicate, string(object))
 			}
+		case string:
+			appendProperty(props, triple.Predicate, object)
 		}
 	}
 
@@ -225,3 +321,42 @@ func (s *Store) tryResolveAll(id string) (map[string]any, bool) {
 		"properties": props,
 	}, true
 }
+
+// appendProperty adds value, a string or nested object, to the values of
+// property. The values are kept as a []string or []map[string]any when they
+// are all the same, otherwise as a []any.
+func appendProperty(props map[string]any, property string, value any) {
+	switch found := props[property].(type) {
+	case nil:
+		switch v := value.(type) {
+		case string:
+			props[property] = []string{v}
+		case map[string]any:
+			props[property] = []map[string]any{v}
+		}
+	case []string:
+		if v, ok := value.(string); ok {
+			props[property] = append(found, v)
+			return
+		}
+
+		mixed := make([]any, 0, len(found)+1)
+		for _, v := range found {
+			mixed = append(mixed, v)
+		}
+		props[property] = append(mixed, value)
+	case []map[string]any:
+		if v, ok := value.(map[string]any); ok {
+			props[property] = append(found, v)
+			return
+		}
+
+		mixed := make([]any, 0, len(found)+1)
+		for _, v := range found {
+			mixed = append(mixed, v)
+		}
+		props[property] = append(mixed, value)
+	case []any:
+		props[property] = append(found, value)
+	}
+}
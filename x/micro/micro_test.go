@@ -173,6 +173,118 @@ func TestInsertPartial(t *testing.T) {
 		no6.Predicates("type").Eq("h-entry")))
 }
 
+func TestInsertRefs(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name(), func(typ string) string { return typ })
+
+	uid, _ := store.Insert(map[string]any{
+		"type": []string{"h-entry"},
+		"properties": map[string]any{
+			"author": []map[string]any{{
+				"type":       []string{"h-card"},
+				"properties": map[string]any{"name": []string{"Alice"}},
+			}},
+			"category": []string{"h-review"},
+		},
+	})
+
+	triples, err := store.inner.Query(no6.Subjects(uid), no6.Predicates("author", "category"))
+	assert.Nil(t, err)
+	assert.Equal(t, []no6.Triple{
+		{Subject: "h-entry", Predicate: "author", Object: no6.Ref("h-card")},
+		{Subject: "h-entry", Predicate: "category", Object: "h-review"},
+	}, triples)
+}
+
+func TestInsertRefProperties(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name(), func(typ string) string { return typ })
+
+	store.Insert(map[string]any{
+		"type":       []string{"h-card"},
+		"properties": map[string]any{"name": []string{"Alice"}},
+	})
+
+	uid, err := store.Insert(map[string]any{
+		"type": []string{"h-entry"},
+		"properties": map[string]any{
+			"author":   []string{"h-card"},
+			"location": []string{"https://example.com/"},
+			"content":  []string{"h-card"},
+		},
+	})
+	assert.Nil(t, err)
+
+	// only a string for author or location that names an object is a reference
+	triples, err := store.inner.Query(no6.Subjects(uid), no6.Predicates("author", "location", "content"))
+	assert.Nil(t, err)
+	assert.Equal(t, []no6.Triple{
+		{Subject: "h-entry", Predicate: "author", Object: no6.Ref("h-card")},
+		{Subject: "h-entry", Predicate: "location", Object: "https://example.com/"},
+		{Subject: "h-entry", Predicate: "content", Object: "h-card"},
+	}, triples)
+}
+
+func TestInsertRefsUnresolved(t *testing.T) {
+	file, _ := os.CreateTemp("", "")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	store, _ := Open(file.Name(), func(typ string) string { return typ })
+
+	store.Insert(map[string]any{
+		"type":       []string{"h-card"},
+		"properties": map[string]any{"name": []string{"Alice"}},
+	})
+
+	uid, err := store.Insert(map[string]any{
+		"type": []string{"h-entry"},
+		"properties": map[string]any{
+			"author":  []no6.Ref{"h-card"},
+			"like-of": []any{"h-card", no6.Ref("h-card"), "https://example.com/"},
+			"content": []string{"h-card"},
+		},
+	})
+	assert.Nil(t, err)
+
+	alice := map[string]any{
+		"type":       []string{"h-card"},
+		"properties": map[string]any{"name": []string{"Alice"}},
+	}
+
+	// a string that is the same as a subject is not a reference to it
+	entry, ok := store.Get(uid)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{
+		"type": []string{"h-entry"},
+		"properties": map[string]any{
+			"author":  []map[string]any{alice},
+			"like-of": []any{alice, "h-card", "https://example.com/"},
+			"content": []string{"h-card"},
+		},
+	}, entry)
+
+	// a reference to a deleted object is kept as its uid
+	assert.Nil(t, store.DeleteByUID("h-card"))
+
+	entry, ok = store.Get(uid)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{
+		"type": []string{"h-entry"},
+		"properties": map[string]any{
+			"author":  []string{"h-card"},
+			"like-of": []string{"h-card", "h-card", "https://example.com/"},
+			"content": []string{"h-card"},
+		},
+	}, entry)
+}
+
 var benchErr error
 
 func BenchmarkStoreInsert(b *testing.B) {
@@ -349,6 +461,24 @@ func TestUseCase(t *testing.T) {
 		assert.True(t, ok)
 	})
 
+	t.Run("replace an entry with invalid data", func(t *testing.T) {
+		store, closer := newStore()
+		defer closer()
+
+		uid, err := store.Insert(post)
+		assert.Nil(t, err)
+
+		err = store.Replace(uid, map[string]any{
+			"type":       []string{"h-entry"},
+			"properties": map[string]any{"content": 5},
+		})
+		assert.NotNil(t, err)
+
+		entry, ok := store.Get(uid)
+		assert.True(t, ok)
+		assert.Equal(t, post, entry)
+	})
+
 	t.Run("get by uid", func(t *testing.T) {
 		store, closer := newStore()
 		defer closer()